@@ -0,0 +1,147 @@
+package rcon
+
+import (
+	"errors"
+	"sync"
+)
+
+// The number of commands ExecuteAsync queues before blocking its caller.
+const asyncQueueSize = 64
+
+// Async errors.
+var (
+	ErrClientDisconnected = errors.New("Client disconnected before the command was executed.")
+)
+
+// Result is the outcome of a command executed asynchronously.
+type Result struct {
+	Packet *Packet // The response packet, nil if the command failed.
+	Err    error   // The error the command failed with.
+}
+
+type asyncRequest struct {
+	command string
+	result  chan Result
+}
+
+// worker executes queued commands one at a time on its own goroutine.
+type worker struct {
+	queue   chan asyncRequest
+	done    chan struct{}
+	stopped chan struct{}
+	senders sync.WaitGroup // The ExecuteAsync calls queueing commands.
+}
+
+// ExecuteAsync queues the command for execution on the client's worker
+// goroutine, returning a channel the Result is delivered on. Commands
+// run in the order they were submitted, serialized with any commands
+// executed synchronously. The caller only blocks once asyncQueueSize
+// commands are pending. Commands still queued when the client
+// disconnects fail with ErrClientDisconnected. See ReplayQueuedOnReconnect
+// for commands queued while the connection drops.
+func (this *Client) ExecuteAsync(command string) <-chan Result {
+	request := asyncRequest{command, make(chan Result, 1)}
+
+	this.asyncMutex.Lock()
+
+	if nil == this.async {
+		this.async = &worker{
+			queue:   make(chan asyncRequest, asyncQueueSize),
+			done:    make(chan struct{}),
+			stopped: make(chan struct{}),
+		}
+
+		go this.work(this.async)
+	}
+
+	async := this.async
+	async.senders.Add(1)
+
+	this.asyncMutex.Unlock()
+
+	defer async.senders.Done()
+
+	// Queue outside the lock, so a full queue doesn't block stopping the
+	// worker.
+	select {
+	case async.queue <- request:
+	case <-async.done:
+		request.result <- Result{Err: ErrClientDisconnected}
+	}
+
+	return request.result
+}
+
+// stopWorker signals the client's worker to stop and waits for it to
+// finish. The connection's closed first, should a worker be running, to
+// abort the command it's executing, if any, whose response may otherwise
+// never arrive without a Timeout.
+func (this *Client) stopWorker() {
+	this.asyncMutex.Lock()
+	async := this.async
+	this.async = nil
+
+	if nil != async {
+		close(async.done)
+	}
+
+	this.asyncMutex.Unlock()
+
+	if nil == async {
+		return
+	}
+
+	if nil != this.connection {
+		this.connection.Close()
+	}
+
+	<-async.stopped
+}
+
+// work executes the worker's queued commands until it's signalled to
+// stop, failing any commands left in the queue.
+func (this *Client) work(async *worker) {
+	defer close(async.stopped)
+
+	// Was the connection lost by the last command executed?
+	lost := false
+
+	for {
+		select {
+		case request := <-async.queue:
+			// Stopping takes precedence over commands still queued.
+			select {
+			case <-async.done:
+				request.result <- Result{Err: ErrClientDisconnected}
+				continue
+			default:
+			}
+
+			if lost {
+				if err := this.reconnect(); nil != err {
+					request.result <- Result{Err: err}
+					continue
+				}
+
+				lost = false
+			}
+
+			packet, err := this.Execute(request.command)
+			request.result <- Result{packet, err}
+
+			lost = this.ReplayQueuedOnReconnect && isConnectionError(err)
+		case <-async.done:
+			// Commands being queued as the worker stops are failed too.
+			async.senders.Wait()
+
+			for {
+				select {
+				case request := <-async.queue:
+					request.result <- Result{Err: ErrClientDisconnected}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package rcon
+
+import (
+	"errors"
+	"time"
+)
+
+// Time allowed for a server to push a banner on connect, and for each
+// packet continuing it.
+const connectBannerTimeout = 250 * time.Millisecond
+
+// ConnectBanner returns the banner the server pushed on connect, such as a
+// MOTD, consumed with ConsumeConnectBanner, or nil if none was pushed. A
+// banner split over several packets is assembled into the first, with the
+// bodies of the others appended. It's replaced on each Connect and
+// reconnection.
+func (this *Client) ConnectBanner() *Packet {
+	return this.banner
+}
+
+// consumeBanner reads the packets the server pushes on connect before
+// anything's written, until it's quiet for connectBannerTimeout. A quiet
+// server isn't an error.
+func (this *Client) consumeBanner() (err error) {
+	this.banner = nil
+
+	defer this.setDeadline()
+
+	for {
+		this.connection.SetReadDeadline(time.Now().Add(connectBannerTimeout))
+
+		var packet *Packet
+
+		if packet, err = this.readPacket(); errors.Is(err, ErrTimeout) {
+			return nil
+		} else if nil != err {
+			return
+		}
+
+		if nil == this.banner {
+			this.banner = packet
+		} else {
+			this.banner.Body += packet.Body
+		}
+	}
+}
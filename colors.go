@@ -0,0 +1,24 @@
+package rcon
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Color control characters used by Source games and SourceMod, leaving
+// tabs, newlines and carriage returns intact.
+var colorCodes = strings.NewReplacer(
+	"\x01", "", "\x02", "", "\x03", "", "\x04", "", "\x05", "", "\x06", "",
+	"\x07", "", "\x08", "", "\x0B", "", "\x0C", "", "\x0E", "", "\x0F", "",
+	"\x10", "",
+)
+
+// Named color tokens used by SourceMod's chat color includes.
+var colorTokens = regexp.MustCompile(`(?i)\{(default|normal|teamcolor|team|red|lightred|darkred|bluegrey|blue|darkblue|lightblue|purple|orchid|orange|yellow|gold|lightgreen|green|lime|olive|grey|grey2|gray|gray2|engine|silver)\}`)
+
+// StripColorCodes removes color codes from s: the control characters
+// \x01-\x08, \x0B, \x0C and \x0E-\x10, and named tokens such as {green}.
+// Braces around other words are kept.
+func StripColorCodes(s string) string {
+	return colorTokens.ReplaceAllString(colorCodes.Replace(s), "")
+}
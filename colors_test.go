@@ -0,0 +1,12 @@
+package rcon
+
+import "testing"
+
+func TestStripColorCodes(t *testing.T) {
+	stripped := StripColorCodes("\x01[SM] \x04Player\x01 was {green}kicked{default} {reason}\n")
+
+	if stripped != "[SM] Player was kicked {reason}\n" {
+		t.Log("Unexpected stripped string", stripped)
+		t.Fail()
+	}
+}
@@ -0,0 +1,548 @@
+package rcon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Command errors.
+var (
+	ErrEchoMismatch       = errors.New("Remote server's echo doesn't match the text sent.")
+	ErrPlayerNotFound     = errors.New("Remote server couldn't find the player.")
+	ErrCvarWaitTimeout    = errors.New("Cvar didn't reach the expected value in time.")
+	ErrLevelChangeTimeout = errors.New("Level change didn't complete in time.")
+	ErrUnknownCommand     = errors.New("Remote server doesn't recognize the command.")
+	ErrUnsafeCommand      = errors.New("Command could run further commands or arguments than intended.")
+)
+
+// The interval at which WaitForCvar polls the cvar.
+const cvarPollInterval = 250 * time.Millisecond
+
+// Acknowledgements, matched case insensitively, with which servers report
+// that a player can't be found.
+var playerNotFound = []string{
+	"not found",
+	"no such",
+	"no user",
+	"couldn't parse player",
+	"no matching client",
+}
+
+// CvarList runs the cvarlist command, assembling its response over as
+// many packets as the server needs, and parses it into its cvars.
+func (this *Client) CvarList() (cvars []Cvar, err error) {
+	var response *Packet
+
+	if response, err = this.sendMultiPacket("cvarlist", nil); nil != err {
+		return
+	}
+
+	return ParseCvarList(response.Body)
+}
+
+// ServerVersion runs the version command and parses the server's version.
+// The result is cached until the client reconnects, so repeated calls
+// don't hit the server again.
+func (this *Client) ServerVersion() (version ServerVersion, err error) {
+	this.versionMutex.Lock()
+	defer this.versionMutex.Unlock()
+
+	if cached := this.version.Load(); nil != cached {
+		return *cached, nil
+	}
+
+	var response *Packet
+
+	if response, err = this.Execute("version"); nil != err {
+		return
+	} else if version, err = ParseVersion(response.Body); nil != err {
+		return
+	}
+
+	this.version.Store(&version)
+
+	return
+}
+
+// GetCvar runs the cvar's name as a command and parses its current value
+// from the server's echo. ErrUnknownCvar is returned if the cvar doesn't
+// exist. The name's checked as per the client's SanitizeMode first.
+func (this *Client) GetCvar(name string) (value string, err error) {
+	var response *Packet
+	var echoed string
+
+	if name, err = this.sanitize(name); nil != err {
+		return
+	} else if response, err = this.Execute(name); nil != err {
+		return
+	} else if echoed, value, err = ParseCvarValue(response.Body); nil != err {
+		return
+	} else if !strings.EqualFold(echoed, name) {
+		value, err = "", ErrUnknownCvar
+	}
+
+	return
+}
+
+// GetCvarBool gets the cvar's value like GetCvar, converting it with
+// ParseCvarBool. ErrCvarType is returned if it isn't a bool.
+func (this *Client) GetCvarBool(name string) (value bool, err error) {
+	var raw string
+
+	if raw, err = this.GetCvar(name); nil != err {
+		return
+	}
+
+	return ParseCvarBool(raw)
+}
+
+// GetCvarInt gets the cvar's value like GetCvar, converting it with
+// ParseCvarInt. ErrCvarType is returned if it isn't an integer.
+func (this *Client) GetCvarInt(name string) (value int, err error) {
+	var raw string
+
+	if raw, err = this.GetCvar(name); nil != err {
+		return
+	}
+
+	return ParseCvarInt(raw)
+}
+
+// GetCvarFloat gets the cvar's value like GetCvar, converting it with
+// ParseCvarFloat. ErrCvarType is returned if it isn't a number.
+func (this *Client) GetCvarFloat(name string) (value float64, err error) {
+	var raw string
+
+	if raw, err = this.GetCvar(name); nil != err {
+		return
+	}
+
+	return ParseCvarFloat(raw)
+}
+
+// DiffCvars reads the cvars from the servers of both clients, returning
+// those whose values differ, each mapped to its values on a and b, for
+// detecting configuration drift across a fleet. Values are compared as
+// reported, so "1" and "1.0" differ. A cvar unknown to one of the servers
+// differs, with an empty value for that server; one unknown to both is
+// left out. Any other error reading a cvar is returned, along with the
+// differences found before it.
+func DiffCvars(a, b *Client, names []string) (diff map[string][2]string, err error) {
+	diff = make(map[string][2]string)
+
+	for _, name := range names {
+		var values [2]string
+		known := 0
+
+		for i, client := range []*Client{a, b} {
+			if values[i], err = client.GetCvar(name); nil == err {
+				known++
+			} else if !errors.Is(err, ErrUnknownCvar) {
+				return
+			}
+		}
+
+		err = nil
+
+		if 1 == known || 2 == known && values[0] != values[1] {
+			diff[name] = values
+		}
+	}
+
+	return
+}
+
+// WaitForCvar polls the cvar until its value is expected, such as for a
+// restart to settle, failing with ErrCvarWaitTimeout, and the last value
+// observed, should it not within timeout. Failing to query the cvar, e.g.
+// with ErrUnknownCvar, stops waiting with the error.
+func (this *Client) WaitForCvar(name, expected string, timeout time.Duration) error {
+	return this.WaitForCvarContext(context.Background(), name, expected, timeout)
+}
+
+// WaitForCvarContext waits for the cvar like WaitForCvar, stopping with
+// ctx's error once ctx is done. Waiting between polls stops immediately;
+// a poll in flight completes first, within the client's Timeout.
+func (this *Client) WaitForCvarContext(ctx context.Context, name, expected string, timeout time.Duration) (err error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		var value string
+
+		if err = ctx.Err(); nil != err {
+			return
+		} else if value, err = this.GetCvar(name); nil != err || value == expected {
+			return
+		} else if time.Now().Add(cvarPollInterval).After(deadline) {
+			return fmt.Errorf("%w Last value was %q.", ErrCvarWaitTimeout, value)
+		}
+
+		if err = sleepContext(ctx, cvarPollInterval); nil != err {
+			return
+		}
+	}
+}
+
+// ChangeLevel changes the map with changelevel and waits until the server
+// reports it in host_map, polling it until the timeout elapses, failing
+// with ErrLevelChangeTimeout. As servers often drop the connection while
+// changing maps, the client reconnects and authorizes again with its
+// password should it break. Changing to the current map returns as soon
+// as it's reported, which may be before it's reloaded.
+func (this *Client) ChangeLevel(mapName string, timeout time.Duration) error {
+	return this.ChangeLevelContext(context.Background(), mapName, timeout)
+}
+
+// ChangeLevelContext changes the map like ChangeLevel, stopping with ctx's
+// error once ctx is done. Waiting between polls stops immediately; a poll
+// in flight completes first, within the client's Timeout, as does a
+// reconnection, within its DialTimeout and Timeout. The map may be changed
+// nonetheless, as changelevel isn't undone.
+func (this *Client) ChangeLevelContext(ctx context.Context, mapName string, timeout time.Duration) (err error) {
+	deadline := time.Now().Add(timeout)
+
+	if err = ctx.Err(); nil != err {
+		return
+	} else if _, err = this.ExecuteArgs("changelevel", mapName); nil != err && !isConnectionError(err) {
+		return
+	}
+
+	for {
+		var current string
+
+		if ctxErr := ctx.Err(); nil != ctxErr {
+			return ctxErr
+		} else if isConnectionError(err) {
+			err = this.reconnect()
+		}
+
+		if nil == err {
+			if current, err = this.GetCvar("host_map"); nil == err && strings.TrimSuffix(current, ".bsp") == mapName {
+				return
+			} else if errors.Is(err, ErrUnknownCvar) {
+				return
+			}
+		}
+
+		if time.Now().Add(cvarPollInterval).After(deadline) {
+			if nil != err {
+				return fmt.Errorf("%w Last error was: %w", ErrLevelChangeTimeout, err)
+			}
+
+			return fmt.Errorf("%w Current map is %q.", ErrLevelChangeTimeout, current)
+		}
+
+		if ctxErr := sleepContext(ctx, cvarPollInterval); nil != ctxErr {
+			return ctxErr
+		}
+	}
+}
+
+// sleepContext sleeps for the duration, returning ctx's error should ctx
+// be done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetCvar sets the cvar to the value, returning the value it held before.
+// ErrUnknownCvar is returned, and nothing set, if the cvar doesn't exist.
+func (this *Client) SetCvar(name, value string) (old string, err error) {
+	if old, err = this.GetCvar(name); nil != err {
+		return
+	}
+
+	_, err = this.ExecuteArgs(name, value)
+
+	return
+}
+
+// Echo runs the echo command with the text, returning the body the server
+// echoed back. As the command has no side effects, it serves as a probe of
+// both the connection and the round trip of the response. ErrEchoMismatch
+// is returned if the echoed body, less its trailing newline, differs from
+// the text.
+func (this *Client) Echo(text string) (echoed string, err error) {
+	var response *Packet
+	var command string
+
+	if command, err = this.sanitize("echo " + text); nil != err {
+		return
+	} else if response, err = this.Execute(command); nil != err {
+		return
+	}
+
+	echoed = strings.TrimRight(response.Body, "\r\n")
+
+	if echoed != text {
+		err = ErrEchoMismatch
+	}
+
+	return
+}
+
+// QuoteArg quotes s as a single argument to a Source console command. The
+// console splits commands on semicolons and arguments on whitespace, except
+// within double quotes, so the argument is wrapped in double quotes. As the
+// console has no way of escaping a double quote inside them, any in s are
+// replaced with single quotes, and control characters, such as newlines,
+// which would end the command, are removed.
+func QuoteArg(s string) string {
+	quoted := strings.Map(func(r rune) rune {
+		if '"' == r {
+			return '\''
+		} else if r < ' ' || 0x7f == r {
+			return -1
+		}
+
+		return r
+	}, s)
+
+	return `"` + quoted + `"`
+}
+
+// SanitizeCommand checks a command built from untrusted input, such as
+// with fmt.Sprintf, for anything that would have the console run more than
+// the one command: control characters, such as newlines, are removed, and
+// semicolons outside double quotes, which separate commands, as well as
+// unbalanced double quotes fail with ErrUnsafeCommand. Arguments are better
+// quoted with QuoteArg, as ExecuteArgs does, which can't fail.
+func SanitizeCommand(s string) (string, error) {
+	return sanitizeCommand(s, SanitizeEscape)
+}
+
+// SanitizeMode is how strictly the helpers building commands, such as
+// ExecuteArgs, Kick and Say, check them with SanitizeCommand.
+type SanitizeMode int
+
+const (
+	// Remove control characters and reject command separators, as
+	// SanitizeCommand does.
+	SanitizeEscape SanitizeMode = iota
+
+	// Reject control characters too, rather than removing them.
+	SanitizeStrict
+
+	// Send commands unchecked, their arguments still quoted.
+	SanitizeOff
+)
+
+// sanitize checks the command as per the client's SanitizeMode.
+func (this *Client) sanitize(command string) (string, error) {
+	return sanitizeCommand(command, this.SanitizeMode)
+}
+
+// sanitizeCommand checks the command as per the mode.
+func sanitizeCommand(s string, mode SanitizeMode) (string, error) {
+	if SanitizeOff == mode {
+		return s, nil
+	}
+
+	var sanitized strings.Builder
+	quoted := false
+
+	for _, r := range s {
+		switch {
+		case r < ' ' || 0x7f == r:
+			if SanitizeStrict == mode {
+				return "", fmt.Errorf("%w Command contains control character %q.", ErrUnsafeCommand, r)
+			}
+
+			continue
+		case '"' == r:
+			quoted = !quoted
+		case ';' == r && !quoted:
+			return "", fmt.Errorf("%w Command contains a separator outside quotes.", ErrUnsafeCommand)
+		}
+
+		sanitized.WriteRune(r)
+	}
+
+	if quoted {
+		return "", fmt.Errorf("%w Command has an unbalanced quote.", ErrUnsafeCommand)
+	}
+
+	return sanitized.String(), nil
+}
+
+// ExecuteArgs executes the command with the arguments, each quoted with
+// QuoteArg, so that arguments from untrusted input, such as player names,
+// can't split into further arguments or commands. The command's checked as
+// per the client's SanitizeMode, failing with ErrUnsafeCommand should it
+// be unsafe, e.g. with a separator in the command's name.
+func (this *Client) ExecuteArgs(command string, args ...string) (response *Packet, err error) {
+	for _, arg := range args {
+		command += " " + QuoteArg(arg)
+	}
+
+	if command, err = this.sanitize(command); nil != err {
+		return
+	}
+
+	return this.Execute(command)
+}
+
+// Kick kicks the player with the user ID from the server, showing them the
+// reason. ErrPlayerNotFound is returned if no player has the user ID.
+func (this *Client) Kick(userID int, reason string) error {
+	return this.executeOnPlayer("kickid", strconv.Itoa(userID), reason)
+}
+
+// Ban bans the player with the Steam ID, e.g. STEAM_0:1:123456, for the
+// minutes, permanently if zero, and kicks them showing them the reason.
+// Players that aren't connected are banned nonetheless. ErrPlayerNotFound
+// is returned if the server can't parse the Steam ID.
+func (this *Client) Ban(steamID string, minutes int, reason string) (err error) {
+	if err = this.executeOnPlayer("banid", strconv.Itoa(minutes), steamID); nil != err {
+		return
+	} else if err = this.executeOnPlayer("kickid", steamID, reason); errors.Is(err, ErrPlayerNotFound) {
+		err = nil
+	}
+
+	return
+}
+
+// Say broadcasts the message to every player with the say command. The
+// message is quoted with QuoteArg, so punctuation, quotes and semicolons in
+// it can't end the command or inject another. ErrUnknownCommand is returned
+// if the server doesn't recognize say.
+func (this *Client) Say(message string) (err error) {
+	var response *Packet
+
+	if response, err = this.ExecuteArgs("say", message); nil != err {
+		return
+	} else if isUnknownCommand(response.Body) {
+		err = fmt.Errorf("%w Command is %q.", ErrUnknownCommand, "say")
+	}
+
+	return
+}
+
+// SayTo sends the message privately to the player, given by name, #userid
+// or Steam ID, with SourceMod's sm_psay, as Source servers have no private
+// messages of their own. Both are quoted with QuoteArg. ErrPlayerNotFound
+// is returned if no player matches, and ErrUnknownCommand if the server
+// doesn't run SourceMod.
+func (this *Client) SayTo(player, message string) error {
+	return this.executeOnPlayer("sm_psay", player, message)
+}
+
+// ListBans lists the server's bans, those of Steam IDs as listed by listid
+// followed by those of IP addresses as listed by listip.
+func (this *Client) ListBans() (bans []Ban, err error) {
+	for _, command := range []string{"listid", "listip"} {
+		var response *Packet
+		var listed []Ban
+
+		if response, err = this.Execute(command); nil != err {
+			return nil, err
+		} else if listed, err = ParseBanList(response.Body); nil != err {
+			return nil, err
+		}
+
+		bans = append(bans, listed...)
+	}
+
+	return
+}
+
+// Plugins lists the server's plugins, as listed by Metamod's meta list,
+// or by SourceMod's sm plugins list on servers without Metamod's command.
+// ErrUnknownCommand is returned if the server runs neither.
+func (this *Client) Plugins() (plugins []Plugin, err error) {
+	var response *Packet
+
+	for _, command := range []string{"meta list", "sm plugins list"} {
+		if response, err = this.ExecuteMultiPacket(command); nil != err {
+			return
+		} else if !isUnknownCommand(response.Body) {
+			return ParsePluginList(response.Body)
+		}
+	}
+
+	return nil, fmt.Errorf("%w Command is %q.", ErrUnknownCommand, "sm plugins list")
+}
+
+// executeOnPlayer executes the command with the arguments, quoted, and
+// checks the acknowledgement for the player not being found.
+func (this *Client) executeOnPlayer(command string, args ...string) (err error) {
+	var response *Packet
+
+	if response, err = this.ExecuteArgs(command, args...); nil != err {
+		return
+	} else if isUnknownCommand(response.Body) {
+		return fmt.Errorf("%w Command is %q.", ErrUnknownCommand, command)
+	}
+
+	body := strings.ToLower(response.Body)
+
+	for _, pattern := range playerNotFound {
+		if strings.Contains(body, pattern) {
+			return ErrPlayerNotFound
+		}
+	}
+
+	return
+}
+
+// isUnknownCommand reports whether the body is the server's response to a
+// command it doesn't recognize.
+func isUnknownCommand(body string) bool {
+	return strings.HasPrefix(strings.TrimSpace(body), "Unknown command")
+}
+
+// CommandResult is the outcome of one of several commands executed.
+type CommandResult struct {
+	Command string  // The command executed.
+	Packet  *Packet // The response packet, nil if the command failed.
+	Err     error   // The error the command failed with.
+}
+
+// ExecuteAll executes the commands in order, returning each command's
+// result. Unlike failing fast, when a command fails because the connection
+// broke, the client reconnects and authorizes again with its stored
+// password, then continues with the remaining commands. The command that
+// failed isn't retried, as whether the server ran it is unknown. Should
+// reconnecting fail, the remaining commands fail with its error.
+func (this *Client) ExecuteAll(commands []string) (results []CommandResult) {
+	results = make([]CommandResult, len(commands))
+
+	for i, command := range commands {
+		packet, err := this.Execute(command)
+		results[i] = CommandResult{command, packet, err}
+
+		if !isConnectionError(err) {
+			continue
+		} else if err = this.reconnect(); nil != err {
+			for j := i + 1; j < len(commands); j++ {
+				results[j] = CommandResult{commands[j], nil, err}
+			}
+
+			return
+		}
+	}
+
+	return
+}
+
+// isConnectionError reports whether the error is from the connection
+// breaking, rather than the command.
+func isConnectionError(err error) bool {
+	var ne net.Error
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, ErrInvalidRead) || errors.As(err, &ne)
+}
@@ -0,0 +1,58 @@
+package rcon
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuoteArg(t *testing.T) {
+	tests := map[string]string{
+		"Player":                    `"Player"`,
+		"Two Words":                 `"Two Words"`,
+		`Say "hi"`:                  `"Say 'hi'"`,
+		"bob; rcon_password hacked": `"bob; rcon_password hacked"`,
+		"\"; quit; echo \"":         `"'; quit; echo '"`,
+		"line\nbreak\x00":           `"linebreak"`,
+		"":                          `""`,
+	}
+
+	for arg, expected := range tests {
+		if quoted := QuoteArg(arg); quoted != expected {
+			t.Logf("Expected %q to quote as %s, got %s", arg, expected, quoted)
+			t.Fail()
+		}
+	}
+}
+
+func TestSanitizeCommand(t *testing.T) {
+	tests := map[string]string{
+		`say "hi; bye"`:  `say "hi; bye"`,
+		"kickid 2\n":     "kickid 2",
+		"sv_cheats 1":    "sv_cheats 1",
+		"say hi; quit":   "",
+		`say "hi`:        "",
+		`say "a" ; quit`: "",
+	}
+
+	for command, expected := range tests {
+		sanitized, err := SanitizeCommand(command)
+
+		if "" == expected && !errors.Is(err, ErrUnsafeCommand) {
+			t.Logf("Expected %q rejected, got %q, %v", command, sanitized, err)
+			t.Fail()
+		} else if "" != expected && (nil != err || sanitized != expected) {
+			t.Logf("Expected %q sanitized as %q, got %q, %v", command, expected, sanitized, err)
+			t.Fail()
+		}
+	}
+
+	if _, err := sanitizeCommand("kickid 2\n", SanitizeStrict); !errors.Is(err, ErrUnsafeCommand) {
+		t.Log("Expected strict sanitization to reject control characters, got", err)
+		t.Fail()
+	}
+
+	if sanitized, err := sanitizeCommand("say hi; quit", SanitizeOff); nil != err || "say hi; quit" != sanitized {
+		t.Log("Expected the command unchecked", sanitized, err)
+		t.Fail()
+	}
+}
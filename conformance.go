@@ -0,0 +1,158 @@
+package rcon
+
+import (
+	"fmt"
+	"time"
+)
+
+// The time allowed for each exchange of the conformance checks.
+const conformanceTimeout = 5 * time.Second
+
+// ConformanceResult is the outcome of one of ConformanceTest's checks.
+type ConformanceResult struct {
+	Check     string // The name of the check.
+	Passed    bool   // Did the server conform?
+	Deviation string // What the server did instead, if it didn't.
+}
+
+// conformanceCheck checks the behavior of the server connected to by the
+// client, returning the deviation observed, if any.
+type conformanceCheck struct {
+	name string
+	run  func(client *Client, password string) string
+}
+
+var conformanceChecks = []conformanceCheck{
+	{"authorization", checkAuthorization},
+	{"authorization failure", checkAuthorizationFailure},
+	{"challenge mirroring", checkChallengeMirroring},
+	{"empty body", checkEmptyBody},
+	{"fragments", checkFragments},
+}
+
+// ConformanceTest checks the RCON server at addr, given as host:port, for
+// conformance to Valve's protocol, for developers of servers implementing
+// it. Each check runs over a connection of its own:
+//
+//	authorization          the password's accepted, the SERVERDATA_AUTH_RESPONSE
+//	                       preceded by an empty SERVERDATA_RESPONSE_VALUE
+//	authorization failure  a wrong password's rejected with a challenge of -1
+//	challenge mirroring    a command's response mirrors its challenge and has
+//	                       the type SERVERDATA_RESPONSE_VALUE
+//	empty body             an empty command is answered
+//	fragments              an empty SERVERDATA_RESPONSE_VALUE sent after a
+//	                       command is mirrored after the command's response
+//
+// The authorization failure check fails to authorize once; servers banning
+// addresses failing repeatedly, as Source servers do, may ban the tester
+// if it's run too often.
+func ConformanceTest(addr, password string) (results []ConformanceResult) {
+	for _, check := range conformanceChecks {
+		client := NewClient("", 0, password)
+		client.Addresses = []string{addr}
+		client.DialTimeout = conformanceTimeout
+		client.Timeout = conformanceTimeout
+
+		var deviation string
+
+		if err := client.Connect(); nil != err {
+			deviation = fmt.Sprint("connecting failed: ", err)
+		} else {
+			deviation = check.run(client, password)
+			client.Disconnect()
+		}
+
+		results = append(results, ConformanceResult{check.name, "" == deviation, deviation})
+	}
+
+	return
+}
+
+func checkAuthorization(client *Client, password string) string {
+	request := newPacket(client.newChallenge(), auth, password, packetPaddingSize)
+
+	client.setDeadline()
+
+	if err := client.writePacket(request); nil != err {
+		return fmt.Sprint("sending the password failed: ", err)
+	}
+
+	response, err := client.readPacket()
+	if nil != err {
+		return fmt.Sprint("reading the response failed: ", err)
+	} else if response.Header.headerType != responseValue || "" != response.Body {
+		return fmt.Sprintf("expected an empty SERVERDATA_RESPONSE_VALUE first, got type %d, body %q", response.Header.headerType, response.Body)
+	}
+
+	if response, err = client.readPacket(); nil != err {
+		return fmt.Sprint("reading the SERVERDATA_AUTH_RESPONSE failed: ", err)
+	} else if response.Header.headerType != authResponse {
+		return fmt.Sprintf("expected a SERVERDATA_AUTH_RESPONSE, got type %d", response.Header.headerType)
+	} else if response.Header.challenge != request.Header.challenge {
+		return fmt.Sprintf("expected the password's accepted mirroring challenge %d, got %d", request.Header.challenge, response.Header.challenge)
+	}
+
+	return ""
+}
+
+func checkAuthorizationFailure(client *Client, password string) string {
+	request := newPacket(client.newChallenge(), auth, password+" wrong", packetPaddingSize)
+
+	client.setDeadline()
+
+	if err := client.writePacket(request); nil != err {
+		return fmt.Sprint("sending the wrong password failed: ", err)
+	}
+
+	for {
+		response, err := client.readPacket()
+		if nil != err {
+			return fmt.Sprint("reading the SERVERDATA_AUTH_RESPONSE failed: ", err)
+		} else if response.Header.headerType == responseValue {
+			continue
+		} else if response.Header.headerType != authResponse {
+			return fmt.Sprintf("expected a SERVERDATA_AUTH_RESPONSE, got type %d", response.Header.headerType)
+		} else if -1 != response.Header.challenge {
+			return fmt.Sprintf("expected the wrong password rejected with challenge -1, got %d", response.Header.challenge)
+		}
+
+		return ""
+	}
+}
+
+func checkChallengeMirroring(client *Client, password string) string {
+	if _, err := client.Authorize(); nil != err {
+		return fmt.Sprint("authorization failed: ", err)
+	}
+
+	for _, challenge := range []int32{1, 0x7fffffff, client.newChallenge()} {
+		response, err := client.RoundTrip(newPacket(challenge, exec, "echo conformance", packetPaddingSize))
+		if nil != err {
+			return fmt.Sprintf("command with challenge %d failed: %v", challenge, err)
+		} else if response.Header.headerType != responseValue {
+			return fmt.Sprintf("expected a SERVERDATA_RESPONSE_VALUE, got type %d", response.Header.headerType)
+		}
+	}
+
+	return ""
+}
+
+func checkEmptyBody(client *Client, password string) string {
+	if _, err := client.Authorize(); nil != err {
+		return fmt.Sprint("authorization failed: ", err)
+	} else if _, err = client.Execute(""); nil != err {
+		return fmt.Sprint("empty command failed: ", err)
+	}
+
+	return ""
+}
+
+func checkFragments(client *Client, password string) string {
+	if _, err := client.Authorize(); nil != err {
+		return fmt.Sprint("authorization failed: ", err)
+	} else if _, err = client.ExecuteMultiPacket("cvarlist"); nil != err {
+		return fmt.Sprint("multi-packet response failed: ", err)
+	}
+
+	return ""
+}
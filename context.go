@@ -0,0 +1,71 @@
+package rcon
+
+import (
+	"context"
+	"fmt"
+)
+
+// WithContext ties the client's lifetime to ctx, such as that of a request
+// or a service's shutdown: once ctx is done, the connection's closed,
+// aborting any command in flight, the client disconnects, and Connect and
+// new commands fail with ErrClientShutdown wrapping ctx's cause. A client
+// whose context is done is terminal until WithContext is called again with
+// a live context, e.g. context.Background() to untie it, before the client
+// is connected anew. Calling WithContext again replaces the earlier
+// context.
+func (this *Client) WithContext(ctx context.Context) {
+	this.ctxMutex.Lock()
+	defer this.ctxMutex.Unlock()
+
+	// Should the earlier context be done, wait for the client to finish
+	// cancelling, so a new connection isn't closed by it.
+	if nil != this.stopCtx && !this.stopCtx() {
+		<-this.ctxDone
+	}
+
+	done := make(chan struct{})
+
+	this.ctx.Store(&ctx)
+	this.ctxDone = done
+	this.stopCtx = context.AfterFunc(ctx, func() {
+		defer close(done)
+		this.cancel()
+	})
+}
+
+// cancel closes the client's connection once its context is done, aborting
+// the command in flight, and stops its background work.
+func (this *Client) cancel() {
+	if nil != this.connection {
+		this.connection.Close()
+	}
+
+	this.stopSession()
+	this.stopRenew()
+	this.stopWorker()
+	this.stopIdle()
+}
+
+// context returns the context the client's lifetime is tied to, or
+// context.Background() if it isn't. It's read without ctxMutex, which
+// WithContext holds while waiting for the earlier context's cancellation
+// to stop commands.
+func (this *Client) context() context.Context {
+	if ctx := this.ctx.Load(); nil != ctx {
+		return *ctx
+	}
+
+	return context.Background()
+}
+
+// contextErr returns the error commands fail with once the client's
+// context is done, or nil if it isn't.
+func (this *Client) contextErr() error {
+	ctx := this.context()
+
+	if nil == ctx.Err() {
+		return nil
+	}
+
+	return fmt.Errorf("%w %w", ErrClientShutdown, context.Cause(ctx))
+}
@@ -0,0 +1,53 @@
+package rcon
+
+import (
+	"encoding/binary"
+)
+
+// DecodePackets decodes as many complete packets as b holds, such as
+// traffic captured by a proxy or read ahead of pipelined responses. The
+// bytes following the last complete packet, a truncated packet to be
+// completed by further reads, are returned as rest, empty if there are
+// none. Bodies are trimmed of the null terminator and padding as per the
+// spec. A packet declaring a size below the header's fails decoding with
+// ErrInvalidRead, and one not null terminated with ErrInvalidTermination;
+// the packets preceding it are returned, and rest starts with it.
+func DecodePackets(b []byte) (packets []*Packet, rest []byte, err error) {
+	decoder := &Client{PaddingSize: packetPaddingSize, PaddingValidation: PaddingStrict}
+
+	for len(b) >= 4 {
+		size := int32(binary.LittleEndian.Uint32(b))
+
+		if size < packetHeaderSize {
+			err = ErrInvalidRead
+			break
+		} else if int64(len(b)-4) < int64(size) {
+			break
+		}
+
+		packet := &Packet{Header: header{
+			size:       size,
+			challenge:  int32(binary.LittleEndian.Uint32(b[4:])),
+			headerType: int32(binary.LittleEndian.Uint32(b[8:])),
+		}}
+
+		// Malformed, without even the null terminator, but seen in the
+		// wild, as when reading; there's no body to decode.
+		if size > packetHeaderSize {
+			var body []byte
+
+			if body, err = decoder.trimBody(b[4+packetHeaderSize : 4+size]); nil != err {
+				break
+			}
+
+			packet.Body = string(body)
+		}
+
+		packets = append(packets, packet)
+		b = b[4+size:]
+	}
+
+	rest = b
+
+	return
+}
@@ -0,0 +1,81 @@
+package rcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestDecodePackets(t *testing.T) {
+	var stream []byte
+
+	for _, packet := range []*Packet{
+		newPacket(1, responseValue, "hostname: mock", packetPaddingSize),
+		newPacket(2, authResponse, "", packetPaddingSize),
+	} {
+		payload, _ := packet.compile()
+		stream = append(stream, payload...)
+	}
+
+	truncated, _ := newPacket(3, responseValue, "players : 0", packetPaddingSize).compile()
+	stream = append(stream, truncated[:9]...)
+
+	packets, rest, err := DecodePackets(stream)
+	if nil != err {
+		t.Log("Expected no error during decode", err)
+		t.FailNow()
+	}
+
+	if len(packets) != 2 || packets[0].Body != "hostname: mock" || packets[1].Header.challenge != 2 {
+		t.Log("Unexpected packets", packets)
+		t.Fail()
+	}
+
+	if !bytes.Equal(rest, truncated[:9]) {
+		t.Log("Expected the truncated packet as the rest", rest)
+		t.Fail()
+	}
+
+	if _, _, err = DecodePackets([]byte{4, 0, 0, 0, 0, 0, 0, 0}); !errors.Is(err, ErrInvalidRead) {
+		t.Log("Expected ErrInvalidRead for an undersized packet, got", err)
+		t.Fail()
+	}
+}
+
+func FuzzDecodePackets(f *testing.F) {
+	for _, packet := range []*Packet{
+		newPacket(42, exec, "status", packetPaddingSize),
+		newPacket(-1, authResponse, "", packetPaddingSize),
+		newPacket(7, responseValue, "unterminated", 0),
+		{Header: header{packetHeaderSize, 1, responseValue}},
+	} {
+		payload, _ := packet.compile()
+		f.Add(payload)
+		f.Add(payload[:len(payload)/2])
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		packets, rest, err := DecodePackets(b)
+
+		if nil == err && len(rest) >= 4 && int64(len(rest)-4) >= int64(int32(binary.LittleEndian.Uint32(rest))) {
+			t.Fatal("Expected the complete packet to be decoded", rest)
+		}
+
+		// Decoded packets reencode to the bytes they were decoded from.
+		var encoded []byte
+
+		for _, packet := range packets {
+			payload, err := packet.compile()
+			if nil != err {
+				t.Fatal("Expected decoded packet to compile", err)
+			}
+
+			encoded = append(encoded, payload...)
+		}
+
+		if !bytes.Equal(encoded, b[:len(b)-len(rest)]) {
+			t.Fatal("Expected decoded packets to reencode to their bytes", packets)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package rcon
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"time"
+)
+
+// ProtocolKind is the flavor of RCON a server speaks.
+type ProtocolKind int
+
+const (
+	ProtocolUnknown   ProtocolKind = iota // Not recognized.
+	ProtocolSource                        // Valve's Source RCON.
+	ProtocolMinecraft                     // Minecraft's variant of Source RCON.
+	ProtocolText                          // A text prompt, as of some proxies.
+)
+
+// String returns the protocol's name.
+func (this ProtocolKind) String() string {
+	switch this {
+	case ProtocolSource:
+		return "source"
+	case ProtocolMinecraft:
+		return "minecraft"
+	case ProtocolText:
+		return "text"
+	default:
+		return "unknown"
+	}
+}
+
+// DetectProtocol detects the flavor of RCON the connected server speaks,
+// for cataloging servers or choosing the client's options. It must be
+// called right after Connect, before anything else is exchanged.
+//
+// Servers sending text before receiving anything, e.g. a password prompt,
+// speak ProtocolText; the text is peeked at, not consumed, so Authorize
+// with AllowTextAuth answers it afterwards. Otherwise the client authorizes
+// with its password, telling Source servers, which precede or follow the
+// SERVERDATA_AUTH_RESPONSE with an empty SERVERDATA_RESPONSE_VALUE, from
+// Minecraft servers, which don't. This authorizes the client as Authorize
+// would, and reveals the protocol even with a wrong password, although
+// Source servers ban addresses failing to authorize repeatedly.
+func (this *Client) DetectProtocol() (kind ProtocolKind, err error) {
+	defer this.wrapError(&err)
+
+	if err = this.lock(); nil != err {
+		return
+	}
+	defer this.mutex.Unlock()
+
+	reader := this.peeker()
+
+	var peeked []byte
+
+	if peeked, err = this.peek(reader, textPromptTimeout); nil != err {
+		return
+	} else if 0 != len(peeked) {
+		if isText(peeked) {
+			kind = ProtocolText
+		}
+
+		return
+	}
+
+	packet := newPacket(this.newChallenge(), auth, this.password, this.PaddingSize)
+
+	var response *Packet
+
+	if err = this.writePacket(packet); nil != err {
+		return
+	} else if err = this.awaitResponse(); nil != err {
+		return
+	} else if response, err = this.readPacket(); nil != err {
+		return
+	}
+
+	switch response.Header.headerType {
+	case responseValue:
+		kind = ProtocolSource
+
+		if response, err = this.readPacket(); nil != err {
+			return
+		}
+	case this.AuthResponseType:
+		kind = ProtocolMinecraft
+
+		// Some Source servers send the empty SERVERDATA_RESPONSE_VALUE
+		// after the SERVERDATA_AUTH_RESPONSE instead; it's discarded
+		// should it precede the next response.
+		if peeked, err = this.peek(reader, drainTimeout); nil != err {
+			return
+		} else if 0 != len(peeked) {
+			kind = ProtocolSource
+			this.latePreamble = true
+			this.authChallenge = packet.Header.challenge
+		}
+	default:
+		return
+	}
+
+	this.authorized = response.Header.headerType == this.AuthResponseType &&
+		response.Header.challenge == packet.Header.challenge
+	this.secretAuth = false
+
+	return
+}
+
+// peekConn reads a connection through a buffered reader, so what the
+// server sends can be peeked at without being consumed.
+type peekConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (this *peekConn) Read(b []byte) (int, error) {
+	return this.reader.Read(b)
+}
+
+// peeker wraps the client's connection in a peekConn, unless it already
+// is, returning its buffered reader.
+func (this *Client) peeker() *bufio.Reader {
+	if conn, ok := this.connection.(*peekConn); ok {
+		return conn.reader
+	}
+
+	conn := &peekConn{this.connection, bufio.NewReader(this.connection)}
+	this.connection = conn
+
+	return conn.reader
+}
+
+// peek returns the bytes the server sends within the timeout, without
+// consuming them. A timeout with nothing sent isn't an error.
+func (this *Client) peek(reader *bufio.Reader, timeout time.Duration) (peeked []byte, err error) {
+	this.connection.SetReadDeadline(time.Now().Add(timeout))
+	defer this.setDeadline()
+
+	if _, err = reader.Peek(1); nil != err {
+		var ne net.Error
+
+		if errors.As(err, &ne) && ne.Timeout() {
+			err = nil
+		}
+
+		return
+	}
+
+	return reader.Peek(reader.Buffered())
+}
@@ -0,0 +1,26 @@
+package rcon
+
+// TextDecoder transcodes text from a legacy encoding to UTF-8. It's
+// satisfied by the decoders of golang.org/x/text, which the package
+// doesn't depend on itself:
+//
+//	client.ResponseEncoding = charmap.Windows1251.NewDecoder()
+type TextDecoder interface {
+	String(s string) (string, error)
+}
+
+// decodeBody transcodes the response's body to UTF-8 with the client's
+// ResponseEncoding, if set.
+func (this *Client) decodeBody(response *Packet) (err error) {
+	if nil == this.ResponseEncoding {
+		return
+	}
+
+	var body string
+
+	if body, err = this.ResponseEncoding.String(response.Body); nil == err {
+		response.Body = body
+	}
+
+	return
+}
@@ -0,0 +1,34 @@
+package rcon
+
+import (
+	"testing"
+)
+
+// latin1 decodes ISO 8859-1, whose bytes are the first 256 code points.
+type latin1 struct{}
+
+func (latin1) String(s string) (string, error) {
+	runes := make([]rune, len(s))
+
+	for i := 0; i < len(s); i++ {
+		runes[i] = rune(s[i])
+	}
+
+	return string(runes), nil
+}
+
+func TestResponseEncoding(t *testing.T) {
+	c := NewClient(hostname, port, pw)
+	c.ResponseEncoding = latin1{}
+
+	response, err := c.inspect(&Packet{Body: "Jos\xe9"})
+	if nil != err {
+		t.Log("Expected no error during inspect", err)
+		t.FailNow()
+	}
+
+	if response.Body != "José" {
+		t.Log("Unexpected transcoded body", response.Body)
+		t.Fail()
+	}
+}
@@ -0,0 +1,58 @@
+package rcon
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables read by NewClientFromEnv.
+const (
+	envHost     = "RCON_HOST"
+	envPort     = "RCON_PORT"
+	envPassword = "RCON_PASSWORD"
+	envTimeout  = "RCON_TIMEOUT"
+)
+
+// NewClientFromEnv creates a new Client configured by the environment
+// variables RCON_HOST, RCON_PORT and RCON_PASSWORD, and optionally
+// RCON_TIMEOUT, a duration such as "5s" used as both the client's dial
+// and command timeout. An error listing the missing variables is
+// returned if any required variable is unset.
+func NewClientFromEnv() (client *Client, err error) {
+	var missing []string
+
+	for _, name := range []string{envHost, envPort, envPassword} {
+		if "" == os.Getenv(name) {
+			missing = append(missing, name)
+		}
+	}
+
+	if 0 != len(missing) {
+		err = fmt.Errorf("Missing environment variables %s.", strings.Join(missing, ", "))
+		return
+	}
+
+	var port int
+	var timeout time.Duration
+
+	if port, err = strconv.Atoi(os.Getenv(envPort)); nil != err {
+		err = fmt.Errorf("Invalid %s: %w", envPort, err)
+		return
+	}
+
+	if value := os.Getenv(envTimeout); "" != value {
+		if timeout, err = time.ParseDuration(value); nil != err {
+			err = fmt.Errorf("Invalid %s: %w", envTimeout, err)
+			return
+		}
+	}
+
+	client = NewClient(os.Getenv(envHost), port, os.Getenv(envPassword))
+	client.DialTimeout = timeout
+	client.Timeout = timeout
+
+	return
+}
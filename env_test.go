@@ -0,0 +1,36 @@
+package rcon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewClientFromEnv(t *testing.T) {
+	t.Setenv(envHost, hostname)
+	t.Setenv(envPort, "27015")
+	t.Setenv(envPassword, pw)
+	t.Setenv(envTimeout, "5s")
+
+	c, err := NewClientFromEnv()
+	if nil != err {
+		t.Log("Expected no error creating client", err)
+		t.FailNow()
+	}
+
+	if c.Host != hostname || c.Port != port || c.password != pw || c.Timeout != 5*time.Second {
+		t.Log("Unexpected client", c)
+		t.Fail()
+	}
+}
+
+func TestNewClientFromEnvMissing(t *testing.T) {
+	t.Setenv(envHost, hostname)
+	t.Setenv(envPort, "")
+	t.Setenv(envPassword, "")
+
+	_, err := NewClientFromEnv()
+	if nil == err || err.Error() != "Missing environment variables RCON_PORT, RCON_PASSWORD." {
+		t.Log("Expected error listing missing variables, got", err)
+		t.Fail()
+	}
+}
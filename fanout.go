@@ -0,0 +1,121 @@
+package rcon
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// DefaultMaxConcurrentDials bounds the simultaneous dials of the fan-out
+// helpers when no bound is given.
+var DefaultMaxConcurrentDials = runtime.NumCPU() * 4
+
+// ConnectMany connects the clients concurrently, returning each client's
+// error from Connect in order. At most maxConcurrentDials dials are made at
+// once, DefaultMaxConcurrentDials if zero or less, so connecting a large
+// fleet doesn't exhaust file descriptors or have every server accept
+// connections at the same instant.
+func ConnectMany(clients []*Client, maxConcurrentDials int) (errs []error) {
+	errs = make([]error, len(clients))
+
+	var wait sync.WaitGroup
+
+	dials := newDialLimit(maxConcurrentDials)
+
+	for i, client := range clients {
+		wait.Add(1)
+
+		go func(i int, client *Client) {
+			defer wait.Done()
+
+			dials.acquire()
+			defer dials.release()
+
+			errs[i] = client.Connect()
+		}(i, client)
+	}
+
+	wait.Wait()
+
+	return
+}
+
+// Multi executes commands on several servers at once, such as a fleet.
+type Multi struct {
+	Clients []*Client // The connected and authorized clients of the servers.
+
+	// The time allowed for all clients to complete a command, no limit if
+	// zero. Clients still executing it once it elapses are reported as
+	// failing with ErrTimeout, although they complete it in the background.
+	Timeout time.Duration
+}
+
+// Execute executes the command on each client concurrently, returning the
+// results keyed by the client's Name, or its address if unnamed; clients
+// should be named uniquely, as results under the same key replace one
+// another. A server failing or being slow doesn't hold up the others.
+func (this Multi) Execute(command string) (results map[string]CommandResult) {
+	type keyed struct {
+		key    string
+		result CommandResult
+	}
+
+	done := make(chan keyed, len(this.Clients))
+	pending := make(map[string]bool, len(this.Clients))
+
+	for _, client := range this.Clients {
+		key := client.Name
+		if "" == key {
+			key = client.addr()
+		}
+
+		pending[key] = true
+
+		go func(key string, client *Client) {
+			packet, err := client.Execute(command)
+			done <- keyed{key, CommandResult{command, packet, err}}
+		}(key, client)
+	}
+
+	var timeout <-chan time.Time
+
+	if this.Timeout > 0 {
+		timer := time.NewTimer(this.Timeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	results = make(map[string]CommandResult, len(this.Clients))
+
+wait:
+	for range this.Clients {
+		select {
+		case result := <-done:
+			results[result.key] = result.result
+			delete(pending, result.key)
+		case <-timeout:
+			break wait
+		}
+	}
+
+	for key := range pending {
+		results[key] = CommandResult{command, nil, ErrTimeout}
+	}
+
+	return
+}
+
+// dialLimit is a semaphore bounding simultaneous dials.
+type dialLimit chan struct{}
+
+func newDialLimit(max int) dialLimit {
+	if max <= 0 {
+		max = DefaultMaxConcurrentDials
+	}
+
+	return make(dialLimit, max)
+}
+
+func (this dialLimit) acquire() { this <- struct{}{} }
+func (this dialLimit) release() { <-this }
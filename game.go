@@ -0,0 +1,78 @@
+package rcon
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownGame is returned by DetectGame for games it doesn't recognize.
+var ErrUnknownGame = errors.New("Remote server's game isn't recognized.")
+
+// Game is the game a server runs.
+type Game int
+
+const (
+	GameUnknown    Game = iota // Not recognized.
+	GameCSGO                   // Counter-Strike: Global Offensive.
+	GameCSS                    // Counter-Strike: Source.
+	GameTF2                    // Team Fortress 2.
+	GameL4D                    // Left 4 Dead.
+	GameL4D2                   // Left 4 Dead 2.
+	GameGMod                   // Garry's Mod.
+	GameHL2DM                  // Half-Life 2: Deathmatch.
+	GameDODS                   // Day of Defeat: Source.
+	GameInsurgency             // Insurgency.
+	GameNMRiH                  // No More Room in Hell.
+)
+
+// The games recognized by DetectGame, with their game directories and
+// Steam app IDs.
+var games = []struct {
+	game  Game
+	dir   string
+	appID int
+}{
+	{GameCSGO, "csgo", 730},
+	{GameCSS, "cstrike", 240},
+	{GameTF2, "tf", 440},
+	{GameL4D, "left4dead", 500},
+	{GameL4D2, "left4dead2", 550},
+	{GameGMod, "garrysmod", 4000},
+	{GameHL2DM, "hl2mp", 320},
+	{GameDODS, "dod", 300},
+	{GameInsurgency, "insurgency", 222880},
+	{GameNMRiH, "nmrih", 224260},
+}
+
+// String returns the game's directory, e.g. "csgo".
+func (this Game) String() string {
+	for _, known := range games {
+		if known.game == this {
+			return known.dir
+		}
+	}
+
+	return "unknown"
+}
+
+// DetectGame infers the game the server runs from the game directory and
+// app ID it reports with the version command, as cached by ServerVersion,
+// for adapting commands and parsers to the game. The status command isn't
+// consulted, as it reports neither. Games not recognized are reported as
+// GameUnknown with ErrUnknownGame, naming the identifiers the server
+// reported.
+func (this *Client) DetectGame() (game Game, err error) {
+	var version ServerVersion
+
+	if version, err = this.ServerVersion(); nil != err {
+		return
+	}
+
+	for _, known := range games {
+		if known.dir == version.Game || known.appID == version.AppID {
+			return known.game, nil
+		}
+	}
+
+	return GameUnknown, fmt.Errorf("%w Game is %q, app ID %d.", ErrUnknownGame, version.Game, version.AppID)
+}
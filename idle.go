@@ -0,0 +1,56 @@
+package rcon
+
+import (
+	"time"
+)
+
+// armIdle restarts the timer closing the connection once it's idle for
+// the client's IdleTimeout. The client's command lock must be held.
+func (this *Client) armIdle() {
+	if this.IdleTimeout <= 0 {
+		return
+	}
+
+	this.idleMutex.Lock()
+	defer this.idleMutex.Unlock()
+
+	this.idleSince = time.Now()
+
+	if nil == this.idleTimer {
+		this.idleTimer = time.AfterFunc(this.IdleTimeout, this.closeIdle)
+	} else {
+		this.idleTimer.Reset(this.IdleTimeout)
+	}
+}
+
+// stopIdle stops the idle timer, and forgets a connection closed for
+// being idle, so it isn't reopened.
+func (this *Client) stopIdle() {
+	this.idleMutex.Lock()
+	defer this.idleMutex.Unlock()
+
+	if nil != this.idleTimer {
+		this.idleTimer.Stop()
+	}
+
+	this.idleClosed.Store(false)
+}
+
+// closeIdle closes the connection for being idle, unless a command was
+// sent since the timer fired.
+func (this *Client) closeIdle() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.idleMutex.Lock()
+	idle := time.Since(this.idleSince) >= this.IdleTimeout
+	this.idleMutex.Unlock()
+
+	if !idle || nil == this.connection || "" == this.ActiveAddr() {
+		return
+	}
+
+	this.connection.Close()
+	this.setActiveAddr("")
+	this.idleClosed.Store(true)
+}
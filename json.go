@@ -0,0 +1,38 @@
+package rcon
+
+import (
+	"encoding/json"
+)
+
+// packetJSON is the JSON representation of a Packet.
+type packetJSON struct {
+	Type      int32  `json:"type"`
+	Challenge int32  `json:"challenge"`
+	Size      int32  `json:"size"`
+	Body      string `json:"body"`
+}
+
+// MarshalJSON encodes the packet's header fields and body as a JSON object
+// of the form {"type":..,"challenge":..,"size":..,"body":..}.
+func (this Packet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(packetJSON{
+		Type:      this.Header.headerType,
+		Challenge: this.Header.challenge,
+		Size:      this.Header.size,
+		Body:      this.Body,
+	})
+}
+
+// UnmarshalJSON decodes a packet from the JSON object produced by MarshalJSON.
+func (this *Packet) UnmarshalJSON(data []byte) (err error) {
+	var decoded packetJSON
+
+	if err = json.Unmarshal(data, &decoded); nil != err {
+		return
+	}
+
+	this.Header = header{decoded.Size, decoded.Challenge, decoded.Type}
+	this.Body = decoded.Body
+
+	return
+}
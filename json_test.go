@@ -0,0 +1,32 @@
+package rcon
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPacketJSON(t *testing.T) {
+	packet := newPacket(42, exec, "status", packetPaddingSize)
+
+	data, err := json.Marshal(packet)
+	if nil != err {
+		t.Log("Expected no error during marshal", err)
+		t.FailNow()
+	}
+
+	if string(data) != `{"type":2,"challenge":42,"size":16,"body":"status"}` {
+		t.Log("Unexpected JSON", string(data))
+		t.Fail()
+	}
+
+	var decoded Packet
+	if err = json.Unmarshal(data, &decoded); nil != err {
+		t.Log("Expected no error during unmarshal", err)
+		t.FailNow()
+	}
+
+	if decoded != *packet {
+		t.Log("Expected decoded packet to equal original", decoded)
+		t.Fail()
+	}
+}
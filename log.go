@@ -0,0 +1,58 @@
+package rcon
+
+import (
+	"time"
+)
+
+// LogEntry records a command sent by the client.
+type LogEntry struct {
+	Name    string        // The client's name, identifying the server.
+	Addr    string        // The address of the server.
+	Command string        // The command, empty for authorization requests.
+	Wire    string        // The command's body as sent, e.g. newline terminated.
+	RTT     time.Duration // The time taken for the command to complete.
+	Err     error         // The error the command failed with, if any.
+	DryRun  bool          // Was the command only logged, not sent?
+
+	// Is the response likely truncated? Only flagged with the client's
+	// WarnOnLikelyTruncation.
+	LikelyTruncated bool
+}
+
+// Logger receives an entry for every command the client sends. The
+// rconslog package adapts a *slog.Logger to it.
+type Logger interface {
+	Log(entry LogEntry)
+}
+
+// trackCommand counts the command, started at start, in the client's
+// metrics and logs it to the client's logger, along with its body as sent
+// on the wire and, if given, whether its response is likely truncated.
+// The password sent by authorization requests is never logged.
+func (this *Client) trackCommand(typ int32, command string, start time.Time, response **Packet, err *error) {
+	this.metrics.commands.Add(1)
+
+	if nil != *err {
+		this.metrics.errors.Add(1)
+	}
+
+	if nil == this.Logger {
+		return
+	}
+
+	if auth == typ {
+		command = ""
+	}
+
+	this.Logger.Log(LogEntry{
+		Name:    this.Name,
+		Addr:    this.ActiveAddr(),
+		Command: command,
+		Wire:    this.commandBody(typ, command),
+		RTT:     time.Since(start),
+		Err:     *err,
+		DryRun:  this.DryRun && auth != typ,
+
+		LikelyTruncated: this.WarnOnLikelyTruncation && nil != response && nil != *response && (*response).LikelyTruncated(),
+	})
+}
@@ -0,0 +1,57 @@
+package rcon
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+// metrics counts a client's activity.
+type metrics struct {
+	commands      atomic.Int64
+	errors        atomic.Int64
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+	reconnects    atomic.Int64
+}
+
+// Metrics is a snapshot of a client's activity since it was created.
+type Metrics struct {
+	Commands      int64 // The number of commands sent, including authorization.
+	Errors        int64 // The number of commands that failed.
+	BytesSent     int64 // The number of bytes written to the server.
+	BytesReceived int64 // The number of bytes read from the server.
+	Reconnects    int64 // The number of reconnections attempted.
+}
+
+// Metrics returns a snapshot of the client's activity.
+func (this *Client) Metrics() Metrics {
+	return Metrics{
+		Commands:      this.metrics.commands.Load(),
+		Errors:        this.metrics.errors.Load(),
+		BytesSent:     this.metrics.bytesSent.Load(),
+		BytesReceived: this.metrics.bytesReceived.Load(),
+		Reconnects:    this.metrics.reconnects.Load(),
+	}
+}
+
+// PublishExpvar publishes the client's metrics with the expvar package, as
+// the variables prefix.commands, prefix.errors, prefix.bytes_sent,
+// prefix.bytes_received and prefix.reconnects, making them visible on
+// /debug/vars. Nothing is published unless this is called. As with
+// expvar.Publish, it panics if any of the variables is already published,
+// so each client needs its own prefix.
+func (this *Client) PublishExpvar(prefix string) {
+	counters := map[string]*atomic.Int64{
+		"commands":       &this.metrics.commands,
+		"errors":         &this.metrics.errors,
+		"bytes_sent":     &this.metrics.bytesSent,
+		"bytes_received": &this.metrics.bytesReceived,
+		"reconnects":     &this.metrics.reconnects,
+	}
+
+	for name, counter := range counters {
+		expvar.Publish(prefix+"."+name, expvar.Func(func() any {
+			return counter.Load()
+		}))
+	}
+}
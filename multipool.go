@@ -0,0 +1,205 @@
+package rcon
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrMultiPoolFull is returned by MultiPool when a server's pool can't be
+// created without exceeding the connection cap, as every other pool has
+// clients checked out.
+var ErrMultiPoolFull = errors.New("Connection cap reached with every pool in use.")
+
+// MultiPool is a set of Pools, one per server address, created on demand,
+// for services executing commands on a fleet of servers with bursty
+// traffic. Each pool holds up to size connections, and pools are created
+// only while their connections stay within the cap on all of them, least
+// recently used pools with no clients checked out being evicted to make
+// room. Pools unused for the idle timeout are evicted too.
+type MultiPool struct {
+	password       string
+	size           int
+	maxConnections int
+	idleTimeout    time.Duration
+
+	pools  map[string]*poolEntry // The pools, keyed by address.
+	closed bool                  // Has the multi-pool been closed?
+	stop   chan struct{}         // Closed to stop evicting idle pools.
+	mutex  sync.Mutex            // Guards the pools.
+}
+
+// poolEntry is a server's pool in a MultiPool.
+type poolEntry struct {
+	pool     *Pool
+	active   int       // The number of commands executing on the pool.
+	lastUsed time.Time // When the last command on the pool completed.
+}
+
+// NewMultiPool creates a multi-pool of servers authorizing with password,
+// with pools of size connections per server, maxConnections connections in
+// all, evicting pools unused for idleTimeout, never if zero. A size less
+// than one is taken as one, as pools without connections would block every
+// command. Addresses are given as host:port.
+func NewMultiPool(password string, size, maxConnections int, idleTimeout time.Duration) *MultiPool {
+	multi := &MultiPool{
+		password:       password,
+		size:           max(size, 1),
+		maxConnections: maxConnections,
+		idleTimeout:    idleTimeout,
+		pools:          make(map[string]*poolEntry),
+		stop:           make(chan struct{}),
+	}
+
+	if idleTimeout > 0 {
+		go multi.evictIdle()
+	}
+
+	return multi
+}
+
+// Execute executes the command on the server at addr, with a client of its
+// pool, creating the pool if need be. A client whose connection broke is
+// disconnected, so it's reconnected on its next checkout.
+func (this *MultiPool) Execute(addr, command string) (response *Packet, err error) {
+	var entry *poolEntry
+
+	if entry, err = this.acquire(addr); nil != err {
+		return
+	}
+	defer this.release(entry)
+
+	var client *Client
+
+	if client, err = entry.pool.Get(context.Background()); nil != err {
+		return
+	}
+
+	if response, err = client.Execute(command); isConnectionError(err) {
+		client.Disconnect()
+	}
+
+	entry.pool.Put(client)
+
+	return
+}
+
+// Pools returns the number of servers pooled.
+func (this *MultiPool) Pools() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return len(this.pools)
+}
+
+// Close closes every pool; Execute fails with ErrPoolClosed afterwards.
+func (this *MultiPool) Close() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.closed {
+		return
+	}
+
+	this.closed = true
+	close(this.stop)
+
+	for addr, entry := range this.pools {
+		entry.pool.Close()
+		delete(this.pools, addr)
+	}
+}
+
+// acquire returns the server's pool, marked active, creating it should
+// there be none, evicting the least recently used idle pools as needed to
+// stay within the connection cap.
+func (this *MultiPool) acquire(addr string) (entry *poolEntry, err error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.closed {
+		return nil, ErrPoolClosed
+	}
+
+	entry, ok := this.pools[addr]
+
+	if !ok {
+		for (len(this.pools)+1)*this.size > this.maxConnections {
+			if !this.evictLeastRecentlyUsed() {
+				return nil, ErrMultiPoolFull
+			}
+		}
+
+		entry = &poolEntry{pool: newPool(this.size, func() *Client {
+			client := NewClient("", 0, this.password)
+			client.Addresses = []string{addr}
+
+			return client
+		})}
+
+		this.pools[addr] = entry
+	}
+
+	entry.active++
+
+	return
+}
+
+// release marks the pool no longer active for the completed command.
+func (this *MultiPool) release(entry *poolEntry) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	entry.active--
+	entry.lastUsed = time.Now()
+}
+
+// evictLeastRecentlyUsed closes and forgets the least recently used pool
+// with no command executing, reporting whether there was one. The mutex
+// must be held.
+func (this *MultiPool) evictLeastRecentlyUsed() bool {
+	var lru string
+	var oldest *poolEntry
+
+	for addr, entry := range this.pools {
+		if 0 == entry.active && (nil == oldest || entry.lastUsed.Before(oldest.lastUsed)) {
+			lru, oldest = addr, entry
+		}
+	}
+
+	if nil == oldest {
+		return false
+	}
+
+	oldest.pool.Close()
+	delete(this.pools, lru)
+
+	return true
+}
+
+// evictIdle periodically closes and forgets the pools unused for the idle
+// timeout, until the multi-pool's closed.
+func (this *MultiPool) evictIdle() {
+	ticker := time.NewTicker(this.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-this.stop:
+			return
+		case <-ticker.C:
+		}
+
+		this.mutex.Lock()
+
+		for addr, entry := range this.pools {
+			if 0 == entry.active && time.Since(entry.lastUsed) >= this.idleTimeout {
+				entry.pool.Close()
+				delete(this.pools, addr)
+			}
+		}
+
+		this.mutex.Unlock()
+	}
+}
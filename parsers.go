@@ -0,0 +1,302 @@
+package rcon
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parser errors.
+var (
+	ErrUnexpectedResponse = errors.New("Failed to parse the response from remote server.")
+	ErrUnknownCvar        = errors.New("Remote server doesn't recognize the cvar.")
+	ErrCvarType           = errors.New("Cvar's value isn't of the requested type.")
+)
+
+// Cvar describes a single console variable or command as listed
+// by the server's cvarlist command.
+type Cvar struct {
+	Name  string   // The name of the cvar or command.
+	Value string   // The current value, "cmd" for console commands.
+	Flags []string // The flags set on the cvar, e.g. "sv" or "cheat".
+	Help  string   // The help text describing the cvar.
+}
+
+// ParseCvarList parses the body of a cvarlist response into its cvars.
+// Each cvar line holds the name, value, flags and help text separated
+// by colons. The leading "cvar list" title, the dashed separators and
+// the trailing "N total convars/concommands" summary are skipped.
+func ParseCvarList(body string) (cvars []Cvar, err error) {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+
+		if "" == line || "cvar list" == line || "" == strings.Trim(line, "-") {
+			continue
+		} else if strings.HasSuffix(line, "total convars/concommands") {
+			continue
+		}
+
+		fields := strings.SplitN(line, ":", 4)
+
+		if len(fields) != 4 {
+			err = ErrUnexpectedResponse
+			return
+		}
+
+		cvar := Cvar{
+			Name:  strings.TrimSpace(fields[0]),
+			Value: strings.TrimSpace(fields[1]),
+			Help:  strings.TrimSpace(fields[3]),
+		}
+
+		for _, flag := range strings.Split(fields[2], ",") {
+			if flag = strings.Trim(strings.TrimSpace(flag), `"`); "" != flag {
+				cvar.Flags = append(cvar.Flags, flag)
+			}
+		}
+
+		cvars = append(cvars, cvar)
+	}
+
+	return
+}
+
+// ServerVersion describes the server build as reported by the version command.
+type ServerVersion struct {
+	Protocol int    // The network protocol version.
+	Patch    string // The executable's version, e.g. "1.38.2.4".
+	Game     string // The game directory, e.g. "csgo".
+	Build    int    // The executable's build number.
+	AppID    int    // The game's Steam app ID, e.g. 730.
+}
+
+var (
+	protocolPattern = regexp.MustCompile(`Protocol version (\d+)`)
+	patchPattern    = regexp.MustCompile(`Exe version (\S+)(?: \(([^)]+)\))?`)
+	buildPattern    = regexp.MustCompile(`Exe build: [^(]*\((\d+)\)(?: \((\d+)\))?`)
+	cvarPattern     = regexp.MustCompile(`(?m)^"([^"]+)" = "([^"]*)"`)
+)
+
+// ParseVersion parses the body of a version response, which reports the
+// protocol version, the executable's version and game, and its build and
+// app ID:
+//
+//	Protocol version 13765 [1210/1210]
+//	Exe version 1.38.2.4 (csgo)
+//	Exe build: 12:34:56 Jan 12 2022 (8012) (730)
+//
+// Only the protocol version is required, the other fields are left
+// empty when the server omits them.
+func ParseVersion(body string) (version ServerVersion, err error) {
+	match := protocolPattern.FindStringSubmatch(body)
+
+	if nil == match {
+		err = ErrUnexpectedResponse
+		return
+	}
+
+	version.Protocol, _ = strconv.Atoi(match[1])
+
+	if match = patchPattern.FindStringSubmatch(body); nil != match {
+		version.Patch = match[1]
+		version.Game = match[2]
+	}
+
+	if match = buildPattern.FindStringSubmatch(body); nil != match {
+		version.Build, _ = strconv.Atoi(match[1])
+		version.AppID, _ = strconv.Atoi(match[2])
+	}
+
+	return
+}
+
+// ParseCvarValue parses the echo the server responds with when a cvar's
+// name is run as a command, e.g.
+//
+//	"sv_cheats" = "0" ( def. "0" )
+//	 notify replicated
+//	 - Allow cheats on server
+//
+// returning the cvar's name and current value. ErrUnknownCvar is returned
+// if the server reports an unknown command.
+func ParseCvarValue(body string) (name, value string, err error) {
+	if match := cvarPattern.FindStringSubmatch(body); nil != match {
+		return match[1], match[2], nil
+	} else if isUnknownCommand(body) {
+		err = ErrUnknownCvar
+	} else {
+		err = ErrUnexpectedResponse
+	}
+
+	return
+}
+
+// ParseCvarBool converts a cvar's value to a bool. Besides the forms
+// accepted by strconv.ParseBool, such as "1" and "false", any number is
+// true if nonzero, as the engine reads boolean cvars. ErrCvarType is
+// returned for other values.
+func ParseCvarBool(value string) (bool, error) {
+	trimmed := strings.TrimSpace(value)
+
+	if b, err := strconv.ParseBool(trimmed); nil == err {
+		return b, nil
+	} else if f, err := strconv.ParseFloat(trimmed, 64); nil == err {
+		return 0 != f, nil
+	}
+
+	return false, fmt.Errorf("%w Value is %q, not a bool.", ErrCvarType, value)
+}
+
+// ParseCvarInt converts a cvar's value to an int. ErrCvarType is returned
+// for values that aren't integers, including floats such as "1.5".
+func ParseCvarInt(value string) (int, error) {
+	i, err := strconv.Atoi(strings.TrimSpace(value))
+	if nil != err {
+		return 0, fmt.Errorf("%w Value is %q, not an int.", ErrCvarType, value)
+	}
+
+	return i, nil
+}
+
+// ParseCvarFloat converts a cvar's value to a float64. ErrCvarType is
+// returned for values that aren't numbers.
+func ParseCvarFloat(value string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if nil != err {
+		return 0, fmt.Errorf("%w Value is %q, not a float.", ErrCvarType, value)
+	}
+
+	return f, nil
+}
+
+// Ban describes a ban listed by the server's listid or listip command.
+type Ban struct {
+	SteamID   string        // The banned Steam ID, empty for IP bans.
+	IP        string        // The banned IP address, empty for ID bans.
+	Permanent bool          // Is the ban permanent?
+	Remaining time.Duration // The time remaining of a temporary ban.
+	Reason    string        // The ban's reason, if the server lists it.
+}
+
+var (
+	banListPattern = regexp.MustCompile(`^(ID|IP) filter list: (?:empty|\d+ entr(?:y|ies))$`)
+	banPattern     = regexp.MustCompile(`^\d+\s+(\S+)\s*:\s*(permanent|([\d.]+) min)(?:\s*:\s*(.*))?$`)
+)
+
+// ParseBanList parses the body of a listid or listip response, a title
+// followed by a line per ban, into its bans:
+//
+//	ID filter list: 2 entries
+//	1 STEAM_0:1:123456 : permanent
+//	2 STEAM_0:0:654321 : 20.000 min
+//
+// An empty list is titled "ID filter list: empty". Whether the bans are of
+// Steam IDs or IP addresses is taken from the title.
+func ParseBanList(body string) (bans []Ban, err error) {
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	match := banListPattern.FindStringSubmatch(strings.TrimSpace(lines[0]))
+
+	if nil == match {
+		err = ErrUnexpectedResponse
+		return
+	}
+
+	byIP := "IP" == match[1]
+
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); "" == line {
+			continue
+		}
+
+		entry := banPattern.FindStringSubmatch(line)
+
+		if nil == entry {
+			return nil, ErrUnexpectedResponse
+		}
+
+		ban := Ban{Permanent: "permanent" == entry[2], Reason: entry[4]}
+
+		if byIP {
+			ban.IP = entry[1]
+		} else {
+			ban.SteamID = entry[1]
+		}
+
+		if !ban.Permanent {
+			minutes, _ := strconv.ParseFloat(entry[3], 64)
+			ban.Remaining = time.Duration(minutes * float64(time.Minute))
+		}
+
+		bans = append(bans, ban)
+	}
+
+	return
+}
+
+// Plugin describes a plugin listed by Metamod's meta list or SourceMod's
+// sm plugins list command.
+type Plugin struct {
+	Index   int    // The plugin's index in the list.
+	Name    string // The plugin's name.
+	Version string // The plugin's version, if listed.
+	Author  string // The plugin's author, if listed.
+	Status  string // The plugin's status, lowercase, e.g. "paused", "running" if not listed.
+}
+
+var (
+	pluginListPattern   = regexp.MustCompile(`^(?:\[SM\] )?(?:Listing \d+ plugins?:|No plugins loaded\.)$`)
+	metaPluginPattern   = regexp.MustCompile(`^\[(\d+)\]\s+(?:<(\w+)>\s+)?(.*?)(?:\s+\(([^)]*)\))?(?:\s+by\s+(.*))?$`)
+	sourcePluginPattern = regexp.MustCompile(`^(\d+)\s+(?:<(\w+)>\s+|(\w+)\s+)?"([^"]*)"(?:\s+\(([^)]*)\))?(?:\s+by\s+(.*))?$`)
+)
+
+// ParsePluginList parses the body of a meta list or sm plugins list
+// response, a title followed by a line per plugin, into its plugins. Both
+// of the commands' formats are recognized:
+//
+//	Listing 2 plugins:
+//	  [01] SourceMod (1.11.0.6911) by AlliedModders LLC
+//	  [02] <Paused> Stripper (1.2.2) by BAILOPAN
+//
+//	[SM] Listing 2 plugins:
+//	  01 "Admin File Reader" (1.11.0.6911) by AlliedModders LLC
+//	  02 Disabled "Basic Votes" (1.11.0.6911) by AlliedModders LLC
+//
+// An empty list is titled "No plugins loaded.".
+func ParsePluginList(body string) (plugins []Plugin, err error) {
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+
+	if !pluginListPattern.MatchString(strings.TrimSpace(lines[0])) {
+		err = ErrUnexpectedResponse
+		return
+	}
+
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); "" == line {
+			continue
+		}
+
+		var plugin Plugin
+		var index string
+
+		if match := metaPluginPattern.FindStringSubmatch(line); nil != match {
+			index, plugin.Status, plugin.Name, plugin.Version, plugin.Author = match[1], match[2], match[3], match[4], match[5]
+		} else if match = sourcePluginPattern.FindStringSubmatch(line); nil != match {
+			index, plugin.Status, plugin.Name, plugin.Version, plugin.Author = match[1], match[2]+match[3], match[4], match[5], match[6]
+		} else {
+			return nil, ErrUnexpectedResponse
+		}
+
+		plugin.Index, _ = strconv.Atoi(index)
+
+		if plugin.Status = strings.ToLower(plugin.Status); "" == plugin.Status {
+			plugin.Status = "running"
+		}
+
+		plugins = append(plugins, plugin)
+	}
+
+	return
+}
@@ -0,0 +1,190 @@
+package rcon
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseCvarList(t *testing.T) {
+	body := "cvar list\n" +
+		"--------------\n" +
+		"_autosave                                : cmd      :                  : Autosave\n" +
+		"sv_cheats                                : 0        : , \"nf\", \"rep\"    : Allow cheats on server\n" +
+		"--------------\n" +
+		"  2 total convars/concommands\n"
+
+	cvars, err := ParseCvarList(body)
+	if nil != err {
+		t.Log("Expected no error during parse", err)
+		t.FailNow()
+	}
+
+	if len(cvars) != 2 {
+		t.Log("Expected 2 cvars, got", len(cvars))
+		t.FailNow()
+	}
+
+	if cvars[1].Name != "sv_cheats" || cvars[1].Value != "0" || cvars[1].Help != "Allow cheats on server" {
+		t.Log("Unexpected cvar", cvars[1])
+		t.Fail()
+	}
+
+	if len(cvars[1].Flags) != 2 || cvars[1].Flags[0] != "nf" || cvars[1].Flags[1] != "rep" {
+		t.Log("Unexpected flags", cvars[1].Flags)
+		t.Fail()
+	}
+}
+
+func TestParseCvarListMalformed(t *testing.T) {
+	if _, err := ParseCvarList("not a cvar line"); ErrUnexpectedResponse != err {
+		t.Log("Expected ErrUnexpectedResponse, got", err)
+		t.Fail()
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	body := "Protocol version 13765 [1210/1210]\n" +
+		"Exe version 1.38.2.4 (csgo)\n" +
+		"Exe build: 12:34:56 Jan 12 2022 (8012) (730)\n"
+
+	version, err := ParseVersion(body)
+	if nil != err {
+		t.Log("Expected no error during parse", err)
+		t.FailNow()
+	}
+
+	if version != (ServerVersion{13765, "1.38.2.4", "csgo", 8012, 730}) {
+		t.Log("Unexpected version", version)
+		t.Fail()
+	}
+
+	if _, err = ParseVersion("Unknown command \"version\""); ErrUnexpectedResponse != err {
+		t.Log("Expected ErrUnexpectedResponse, got", err)
+		t.Fail()
+	}
+}
+
+func TestParseCvarValue(t *testing.T) {
+	name, value, err := ParseCvarValue("\"sv_cheats\" = \"0\" ( def. \"0\" )\n notify replicated\n - Allow cheats on server\n")
+	if nil != err {
+		t.Log("Expected no error during parse", err)
+		t.FailNow()
+	}
+
+	if name != "sv_cheats" || value != "0" {
+		t.Log("Unexpected cvar", name, value)
+		t.Fail()
+	}
+
+	if _, _, err = ParseCvarValue("Unknown command \"sv_nonexistent\"\n"); ErrUnknownCvar != err {
+		t.Log("Expected ErrUnknownCvar, got", err)
+		t.Fail()
+	}
+}
+
+func TestParseCvarTypes(t *testing.T) {
+	for value, expected := range map[string]bool{"1": true, "0": false, "true": true, "0.000": false, "2": true} {
+		if b, err := ParseCvarBool(value); nil != err || b != expected {
+			t.Log("Unexpected bool from", value, b, err)
+			t.Fail()
+		}
+	}
+
+	if i, err := ParseCvarInt("27015"); nil != err || 27015 != i {
+		t.Log("Unexpected int", i, err)
+		t.Fail()
+	}
+
+	if f, err := ParseCvarFloat("0.03"); nil != err || 0.03 != f {
+		t.Log("Unexpected float", f, err)
+		t.Fail()
+	}
+
+	if _, err := ParseCvarBool("maybe"); !errors.Is(err, ErrCvarType) {
+		t.Log("Expected ErrCvarType for a bool, got", err)
+		t.Fail()
+	}
+
+	if _, err := ParseCvarInt("1.5"); !errors.Is(err, ErrCvarType) {
+		t.Log("Expected ErrCvarType for an int, got", err)
+		t.Fail()
+	}
+
+	if _, err := ParseCvarFloat("de_dust2"); !errors.Is(err, ErrCvarType) {
+		t.Log("Expected ErrCvarType for a float, got", err)
+		t.Fail()
+	}
+}
+
+func TestParseBanList(t *testing.T) {
+	bans, err := ParseBanList("ID filter list: 2 entries\n1 STEAM_0:1:123456 : permanent\n2 STEAM_0:0:654321 : 20.000 min\n")
+	if nil != err {
+		t.Log("Expected no error during parse", err)
+		t.FailNow()
+	}
+
+	if 2 != len(bans) || "STEAM_0:1:123456" != bans[0].SteamID || !bans[0].Permanent || 20*time.Minute != bans[1].Remaining {
+		t.Log("Unexpected bans", bans)
+		t.Fail()
+	}
+
+	if bans, err = ParseBanList("IP filter list: 1 entry\n1 10.0.0.2 : 30.000 min\n"); nil != err || 1 != len(bans) || "10.0.0.2" != bans[0].IP {
+		t.Log("Unexpected IP bans", bans, err)
+		t.Fail()
+	}
+
+	if bans, err = ParseBanList("IP filter list: empty\n"); nil != err || 0 != len(bans) {
+		t.Log("Expected no bans from an empty list", bans, err)
+		t.Fail()
+	}
+
+	if _, err = ParseBanList("Unknown command \"listid\"\n"); ErrUnexpectedResponse != err {
+		t.Log("Expected ErrUnexpectedResponse, got", err)
+		t.Fail()
+	}
+}
+
+func TestParsePluginList(t *testing.T) {
+	plugins, err := ParsePluginList("Listing 2 plugins:\n  [01] SourceMod (1.11.0.6911) by AlliedModders LLC\n  [02] <Paused> Stripper (1.2.2) by BAILOPAN\n")
+	if nil != err {
+		t.Log("Expected no error during parse", err)
+		t.FailNow()
+	}
+
+	expected := []Plugin{
+		{1, "SourceMod", "1.11.0.6911", "AlliedModders LLC", "running"},
+		{2, "Stripper", "1.2.2", "BAILOPAN", "paused"},
+	}
+
+	if !slices.Equal(plugins, expected) {
+		t.Log("Unexpected Metamod plugins", plugins)
+		t.Fail()
+	}
+
+	if plugins, err = ParsePluginList("[SM] Listing 2 plugins:\n  01 \"Admin File Reader\" (1.11.0.6911) by AlliedModders LLC\n  02 Disabled \"Basic Votes\" (1.11.0.6911) by AlliedModders LLC\n"); nil != err {
+		t.Log("Expected no error during parse", err)
+		t.FailNow()
+	}
+
+	expected = []Plugin{
+		{1, "Admin File Reader", "1.11.0.6911", "AlliedModders LLC", "running"},
+		{2, "Basic Votes", "1.11.0.6911", "AlliedModders LLC", "disabled"},
+	}
+
+	if !slices.Equal(plugins, expected) {
+		t.Log("Unexpected SourceMod plugins", plugins)
+		t.Fail()
+	}
+
+	if plugins, err = ParsePluginList("[SM] No plugins loaded.\n"); nil != err || 0 != len(plugins) {
+		t.Log("Expected no plugins from an empty list", plugins, err)
+		t.Fail()
+	}
+
+	if _, err = ParsePluginList("Unknown command \"meta\"\n"); ErrUnexpectedResponse != err {
+		t.Log("Expected ErrUnexpectedResponse, got", err)
+		t.Fail()
+	}
+}
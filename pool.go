@@ -0,0 +1,188 @@
+package rcon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Pool errors.
+var (
+	ErrPoolClosed = errors.New("Pool closed.")
+	ErrPoolWarmup = errors.New("Too few of the pool's connections were established.")
+)
+
+// Pool is a fixed size set of clients of the same server, for executing
+// commands concurrently over several connections. Clients are checked out
+// with Get and returned with Put; each connects and authorizes lazily on
+// its first checkout, unless the pool is warmed up with Warmup.
+type Pool struct {
+	// The dials Warmup makes at once at most, DefaultMaxConcurrentDials if
+	// zero or less.
+	MaxConcurrentDials int
+
+	idle   chan *Client // The clients not checked out.
+	closed bool         // Has the pool been closed?
+	mutex  sync.Mutex   // Guards closing the pool against returning clients.
+}
+
+// NewPool creates a pool of size clients of the server at host and port,
+// authorizing with password. No connections are made until clients are
+// checked out or the pool is warmed up.
+func NewPool(host string, port int, password string, size int) *Pool {
+	return newPool(size, func() *Client {
+		return NewClient(host, port, password)
+	})
+}
+
+// newPool creates a pool of size clients created by newClient.
+func newPool(size int, newClient func() *Client) *Pool {
+	pool := &Pool{idle: make(chan *Client, size)}
+
+	for i := 0; i < size; i++ {
+		pool.idle <- newClient()
+	}
+
+	return pool
+}
+
+// Warmup connects and authorizes the pool's idle clients concurrently, so
+// the first commands executed with them aren't slowed by connecting. Dials
+// are bounded by MaxConcurrentDials. It returns the number of
+// clients connected and authorized, failing with ErrPoolWarmup should it
+// be less than min. Warmup stops waiting once ctx is done, counting only
+// the clients ready by then; the others still complete connecting in the
+// background. Clients checked out during Warmup aren't warmed up.
+func (this *Pool) Warmup(ctx context.Context, min int) (connected int, err error) {
+	var clients []*Client
+
+	for drained := false; !drained; {
+		select {
+		case client, ok := <-this.idle:
+			if !ok {
+				return 0, ErrPoolClosed
+			}
+
+			clients = append(clients, client)
+		default:
+			drained = true
+		}
+	}
+
+	results := make(chan error, len(clients))
+	dials := newDialLimit(this.MaxConcurrentDials)
+
+	for _, client := range clients {
+		go func(client *Client) {
+			dials.acquire()
+			defer dials.release()
+
+			err := ctx.Err()
+			if nil == err {
+				err = this.ready(client)
+			}
+
+			this.Put(client)
+			results <- err
+		}(client)
+	}
+
+wait:
+	for range clients {
+		select {
+		case err := <-results:
+			if nil == err {
+				connected++
+			}
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	if connected < min {
+		err = fmt.Errorf("%w %d of %d connected.", ErrPoolWarmup, connected, len(clients))
+
+		if nil != ctx.Err() {
+			err = errors.Join(err, ctx.Err())
+		}
+	}
+
+	return
+}
+
+// Get checks out a client, waiting for one to be returned should all be
+// checked out, connecting and authorizing it if it isn't. Failing to,
+// the client's returned to the pool and the error returned.
+func (this *Pool) Get(ctx context.Context) (client *Client, err error) {
+	var ok bool
+
+	select {
+	case client, ok = <-this.idle:
+		if !ok {
+			return nil, ErrPoolClosed
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if err = this.ready(client); nil != err {
+		this.Put(client)
+		client = nil
+	}
+
+	return
+}
+
+// Put returns a client checked out with Get to the pool. A client whose
+// connection failed should be disconnected first, so it's reconnected on
+// its next checkout. Clients returned after the pool's closed are
+// disconnected.
+func (this *Pool) Put(client *Client) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.closed {
+		client.Disconnect()
+		return
+	}
+
+	this.idle <- client
+}
+
+// Close disconnects the pool's idle clients, and those checked out as
+// they're returned. Get fails with ErrPoolClosed afterwards.
+func (this *Pool) Close() {
+	this.mutex.Lock()
+
+	if this.closed {
+		this.mutex.Unlock()
+		return
+	}
+
+	this.closed = true
+	close(this.idle)
+
+	this.mutex.Unlock()
+
+	for client := range this.idle {
+		client.Disconnect()
+	}
+}
+
+// ready connects and authorizes the client, unless it already is.
+func (this *Pool) ready(client *Client) (err error) {
+	if client.Authorized() && "" != client.ActiveAddr() {
+		return nil
+	}
+
+	client.Disconnect()
+
+	if err = client.Connect(); nil != err {
+		return
+	}
+
+	_, err = client.Authorize()
+
+	return
+}
@@ -0,0 +1,68 @@
+package rcon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Probe errors.
+var (
+	ErrConnectionRefused = errors.New("Remote server refused the connection.")
+	ErrProbeTimeout      = errors.New("Remote server didn't respond in time.")
+	ErrConnectionClosed  = errors.New("Remote server closed the connection.")
+)
+
+// Probe checks the RCON server at host and port is reachable without
+// authorizing to it, e.g. for a public uptime check. It dials the server
+// and sends an empty SERVERDATA_RESPONSE_VALUE, then waits up to timeout
+// for the server to respond or close the connection. Servers commonly
+// ignore packets before authorization, so the server holding the
+// connection open until timeout counts as reachable.
+//
+// ErrConnectionRefused, ErrProbeTimeout and ErrConnectionClosed classify
+// the common failures, any other error is returned as is.
+func Probe(host string, port int, timeout time.Duration) (err error) {
+	var conn net.Conn
+
+	if conn, err = net.DialTimeout("tcp", fmt.Sprintf("%v:%v", host, port), timeout); nil != err {
+		return classifyProbeError(err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	prober := &Client{connection: conn}
+
+	if err = prober.writePacket(newPacket(0, responseValue, "", packetPaddingSize)); nil != err {
+		return classifyProbeError(err)
+	}
+
+	var size int32
+
+	if err = binary.Read(conn, binary.LittleEndian, &size); nil == err {
+		return nil
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return nil
+	}
+
+	return classifyProbeError(err)
+}
+
+// classifyProbeError maps the error to one of the probe errors, if it
+// matches.
+func classifyProbeError(err error) error {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrConnectionRefused
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return ErrProbeTimeout
+	} else if io.EOF == err || errors.Is(err, syscall.ECONNRESET) {
+		return ErrConnectionClosed
+	}
+
+	return err
+}
@@ -0,0 +1,49 @@
+package rcon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Protocol errors.
+var (
+	ErrUnsupportedProtocol = errors.New("Remote server's protocol version isn't supported.")
+)
+
+// Time allowed for a server to advertise its protocol version on connect.
+const protocolAdvertTimeout = 500 * time.Millisecond
+
+// RequireProtocolRange has Connect read the protocol version the server
+// advertises on connect, in a packet whose body reads e.g. "Protocol
+// version 3", and fail with ErrUnsupportedProtocol should it be outside
+// min and max, inclusive, or not be advertised within a moment, rather than
+// failing later with confusing parse errors. Most Valve servers don't
+// advertise their protocol version, so it's only checked if required.
+func (this *Client) RequireProtocolRange(min, max int) {
+	this.protocolRange = true
+	this.protocolMin = min
+	this.protocolMax = max
+}
+
+// checkProtocol reads the protocol version advertised by the server and
+// checks it against the required range.
+func (this *Client) checkProtocol() (err error) {
+	this.connection.SetReadDeadline(time.Now().Add(protocolAdvertTimeout))
+	defer this.setDeadline()
+
+	var packet *Packet
+	var version ServerVersion
+
+	if packet, err = this.readPacket(); errors.Is(err, ErrTimeout) {
+		return fmt.Errorf("%w No version was advertised.", ErrUnsupportedProtocol)
+	} else if nil != err {
+		return
+	} else if version, err = ParseVersion(packet.Body); nil != err {
+		return fmt.Errorf("%w No version was advertised.", ErrUnsupportedProtocol)
+	} else if version.Protocol < this.protocolMin || version.Protocol > this.protocolMax {
+		return fmt.Errorf("%w Version %d is outside %d to %d.", ErrUnsupportedProtocol, version.Protocol, this.protocolMin, this.protocolMax)
+	}
+
+	return
+}
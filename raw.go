@@ -0,0 +1,74 @@
+package rcon
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// Time SendRaw waits for the server's first bytes without a Timeout.
+const rawResponseTimeout = time.Second
+
+// SendRaw writes the payload to the connection verbatim and returns the
+// raw bytes the server sends back, for reverse engineering non-standard
+// RCON variants. It's a research and debugging tool only.
+//
+// SendRaw bypasses the protocol entirely: no packet's framed, no challenge
+// is checked, and nothing's logged or dry run. Bytes are read until the
+// server's quiet for a moment after its first, waited for within the
+// client's Timeout, or a second without one, so a response arriving later
+// is left on the connection, where it's taken for the next command's.
+// Any payload that isn't a complete, well formed packet can desync the
+// session for good; reconnect before executing commands again. It holds
+// the command lock like any command. At most MaxResponseBytes are
+// returned, if set. Nothing sent back within the time isn't an error;
+// should the connection fail, the bytes read before are returned with the
+// error.
+func (this *Client) SendRaw(payload []byte) (response []byte, err error) {
+	defer this.wrapError(&err)
+
+	if err = this.lock(); nil != err {
+		return
+	}
+	defer this.mutex.Unlock()
+	defer this.setDeadline()
+
+	if err = this.flush(); nil != err {
+		return
+	} else if _, err = this.connection.Write(payload); nil != err {
+		translateTimeout(&err)
+		return
+	}
+
+	if 0 < this.ReadDelay {
+		time.Sleep(this.ReadDelay)
+	}
+
+	deadline := this.deadline
+	if deadline.IsZero() {
+		deadline = time.Now().Add(rawResponseTimeout)
+	}
+
+	buffer := make([]byte, 4096)
+
+	var ne net.Error
+
+	for {
+		this.connection.SetReadDeadline(deadline)
+
+		var n int
+
+		n, err = this.connection.Read(buffer)
+		response = append(response, buffer[:n]...)
+
+		if errors.As(err, &ne) && ne.Timeout() {
+			return response, nil
+		} else if nil != err {
+			return
+		} else if this.MaxResponseBytes > 0 && len(response) > this.MaxResponseBytes {
+			return response[:this.MaxResponseBytes], nil
+		}
+
+		deadline = time.Now().Add(drainTimeout)
+	}
+}
@@ -3,13 +3,21 @@
 package rcon
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -37,14 +45,407 @@ var (
 	ErrInvalidChallenge    = errors.New("Server failed to mirror request challenge.")
 	ErrUnauthorizedRequest = errors.New("Client not authorized to remote server.")
 	ErrFailedAuthorization = errors.New("Failed to authorize to the remote server.")
+	ErrInvalidPaddingSize  = errors.New("Packet padding size must be 1 or 2 bytes.")
+	ErrRateLimited         = errors.New("Remote server is rate limiting or has banned the client.")
+	ErrClientShutdown      = errors.New("Client is shutting down.")
+	ErrInvalidTermination  = errors.New("Packet body isn't null terminated and padded.")
+	ErrResponseTooLarge    = errors.New("Response exceeds the client's maximum size.")
+	ErrResponseWrite       = errors.New("Failed to write the response to the writer.")
+	ErrIncompleteResponse  = errors.New("Response's fragments stopped arriving before its end.")
+	ErrNoMatch             = errors.New("Response doesn't match the pattern.")
+	ErrPasswordTooLong     = errors.New("Password exceeds the longest password read.")
+	ErrNotConnected        = errors.New("Client isn't connected to the remote server.")
 )
 
+// Response bodies, matched case insensitively, with which servers commonly
+// signal that they're throttling or have banned a client.
+var DefaultThrottlePatterns = []string{
+	"banned by server",
+	"you have been banned",
+	"too many commands",
+	"flood protection",
+	"rate limited",
+}
+
+// Time allowed for stray bytes to arrive while draining the connection.
+const drainTimeout = 100 * time.Millisecond
+
+// The body size from which a response's likely split over several packets:
+// Valve's limit of 4096 bytes per packet, less the header and padding, and
+// a margin for servers counting the packet's size differently.
+const fragmentBodySize = int(4096-packetHeaderSize-packetPaddingSize) - 16
+
+// The default time allowed for each fragment of a response to arrive.
+const defaultFragmentTimeout = 2 * time.Second
+
+// The default initial capacity of the buffer response bodies are read into.
+const defaultReadBufferSize = 4096
+
+// Longest password AuthorizeReader reads.
+const maxPasswordLength = 4096
+
 type Client struct {
 	Host       string // The IP address of the remote server.
 	Port       int    // The Port the remote server's listening on.
 	password   string
-	authorized bool     // Has the client been authorized by the server?
-	connection net.Conn // The TCP connection to the server.
+	authorized bool       // Has the client been authorized by the server?
+	connection net.Conn   // The TCP connection to the server.
+	mutex      sync.Mutex // Serializes commands over the connection.
+	async      *worker    // Executes commands submitted by ExecuteAsync.
+	asyncMutex sync.Mutex // Guards starting and stopping the async worker.
+
+	version      atomic.Pointer[ServerVersion] // The server's version, cached once queried.
+	versionMutex sync.Mutex                    // Serializes querying the server's version.
+	lastCommand  time.Time                     // When the last command was sent.
+	activeAddr   atomic.Pointer[string]        // The address the client's connected to.
+	closing      atomic.Bool                   // Is the client shutting down?
+	recorder     *recorder                     // Records the traffic of each connection.
+
+	latePreamble  bool  // May authorization's SERVERDATA_RESPONSE_VALUE follow?
+	authChallenge int32 // The challenge of the last authorization.
+	secretAuth    bool  // Was the client authorized by AuthorizeReader?
+
+	metrics metrics // Counters of the client's activity.
+
+	session      chan struct{} // Closed to stop the persistent session.
+	sessionMutex sync.Mutex    // Guards starting and stopping the session.
+
+	idleTimer  *time.Timer // Closes the connection once idle.
+	idleMutex  sync.Mutex  // Guards the idle timer.
+	idleSince  time.Time   // When the last command started.
+	idleClosed atomic.Bool // Was the connection closed for being idle?
+
+	writer     *bufio.Writer // Buffers packets written with BufferedWrites.
+	writerConn net.Conn      // The connection the writer writes to.
+
+	reuseBuffer []byte // Bodies are read into, reused between commands.
+
+	protocolRange bool // Must the server advertise a supported protocol?
+	protocolMin   int  // The oldest protocol version supported.
+	protocolMax   int  // The newest protocol version supported.
+
+	reconnectAttempts atomic.Int64 // Reconnections counted against MaxReconnects.
+
+	banner *Packet // The banner pushed by the server on connect.
+
+	deadline time.Time // The running command's deadline, zero if none.
+
+	ctx      atomic.Pointer[context.Context] // The context the client's lifetime is tied to.
+	stopCtx  func() bool                     // Stops watching ctx.
+	ctxDone  chan struct{}                   // Closed once the client's cancelled for ctx.
+	ctxMutex sync.Mutex                      // Serializes replacing the client's context.
+
+	renewStop  chan struct{} // Closed to stop renewing authorization.
+	renewErrs  chan error    // Receives the errors renewals fail with.
+	renewMutex sync.Mutex    // Guards starting and stopping renewals.
+
+	sentinel atomic.Int32 // The server's SentinelSupport.
+
+	// Drain the connection and resend a command once, with a fresh
+	// challenge, when the server's response fails to mirror it.
+	RetryOnChallengeMismatch bool
+
+	// The number of null bytes terminating each packet sent, 2 by default
+	// as per Valve's spec. Servers terminating packets with a single null
+	// and no further padding require 1.
+	PaddingSize int32
+
+	// The minimum time between two commands sent by the client; send
+	// sleeps if the last command was too recent. As the wait happens while
+	// holding the client's command lock, concurrent callers are throttled
+	// together. Pooled clients are throttled independently of one another.
+	MinCommandInterval time.Duration
+
+	// An optional limiter waited on before each command is sent, such as
+	// a *rate.Limiter from golang.org/x/time/rate. Sharing one limiter
+	// between the clients of a pool throttles the pool as a whole. It's
+	// waited on with the client's context, see WithContext.
+	Limiter Limiter
+
+	// Send commands before the client's authorized, rather than failing
+	// them locally with ErrUnauthorizedRequest, to probe how the server
+	// responds. Servers typically close the connection or respond with a
+	// challenge of -1.
+	AllowUnauthorizedExec bool
+
+	// Response bodies containing any of these patterns, matched case
+	// insensitively, fail with ErrRateLimited so callers can back off. No
+	// detection if nil, as by default, since ordinary responses, such as
+	// echoes and cvar descriptions, may contain them too. Set it to a copy
+	// of DefaultThrottlePatterns, e.g. with slices.Clone, to opt in.
+	ThrottlePatterns []string
+
+	// Strip color control characters and named color tokens, such as those
+	// of Source and SourceMod, from response bodies. See StripColorCodes.
+	StripColors bool
+
+	// Addresses, in order of preference, of servers to fail over between,
+	// e.g. a primary and backup. When set, Connect and reconnections dial
+	// each in turn until one accepts the connection; Host and Port are
+	// ignored. Every dial starts over from the first address, so the client
+	// returns to the primary as soon as it accepts connections again.
+	Addresses []string
+
+	// The time allowed for dialing each address, no limit if zero.
+	DialTimeout time.Duration
+
+	// Strip all trailing nulls from response bodies, as older versions of
+	// the package did, rather than exactly the null terminator and padding,
+	// for servers not terminating packets as per the spec. Equivalent to,
+	// and kept for compatibility with, PaddingValidation's PaddingIgnore.
+	LenientTrim bool
+
+	// The maximum size of a response's body, whether read from a single
+	// packet or assembled from multiple packets, no limit if zero. Reading
+	// stops once a response exceeds it, or a packet declares a larger
+	// size, failing with ErrResponseTooLarge; the connection should be
+	// reconnected, as the rest of the response remains unread.
+	MaxResponseBytes int
+
+	// An optional logger receiving an entry for every command sent.
+	Logger Logger
+
+	// The time allowed for each command to complete, including writing
+	// it and reading its response, no limit if zero. Commands exceeding
+	// it fail with ErrTimeout.
+	Timeout time.Duration
+
+	// The bytes written as the null terminator and padding of each packet
+	// sent, in place of nulls, for testing how servers tolerate deviations
+	// from the spec. Only the first PaddingSize bytes are written. Leave
+	// zeroed for conforming servers.
+	PaddingBytes [2]byte
+
+	// Read the SERVERDATA_AUTH_RESPONSE directly on authorization, rather
+	// than discarding any empty SERVERDATA_RESPONSE_VALUE preceding or
+	// following it, for servers that never send one.
+	SkipAuthPreambleDiscard bool
+
+	// A stable name identifying the client, e.g. the server's name in a
+	// fleet, included in its log entries and prefixed to its errors.
+	// Omitted from both if empty.
+	Name string
+
+	// Detect servers prompting for the password as text on connect, as
+	// some RCON proxies do, and answer the prompt instead of sending a
+	// SERVERDATA_AUTH packet. Authorization waits briefly for a prompt.
+	AllowTextAuth bool
+
+	// The command run by TailConsole to have the server push its console
+	// output, if the server requires one.
+	TailConsoleCommand string
+
+	// The SO_LINGER seconds set on the connection before Disconnect closes
+	// it. Zero discards unsent data and resets the connection immediately,
+	// skipping TIME_WAIT, so scanners closing many connections don't run out
+	// of ephemeral ports; servers may log the reset as an error, however.
+	// Negative, the default set by NewClient, keeps the system's behavior.
+	LingerSeconds int
+
+	// Terminate the body of each SERVERDATA_EXECCOMMAND with a newline,
+	// for servers whose console parser only executes newline terminated
+	// input, typically those forwarding RCON to a line based console, such
+	// as game server wrappers and hosting panel proxies. Source servers
+	// don't need it.
+	AppendNewline bool
+
+	// An optional callback invoked with each fragment of a response the
+	// server split over several packets, such as CvarList's, as it's read
+	// and before the response is assembled, numbering fragments from zero.
+	// Each fragment's reported before it's written to ExecuteTo's writer,
+	// or left out by ExecuteLimited, so it sees fragments those discard;
+	// the sentinel's packets aren't reported. It's called while holding
+	// the client's command lock, so it must not send commands with the
+	// client.
+	OnFragment func(index int, body string)
+
+	// The packet type signaling a successful authorization, set to
+	// SERVERDATA_AUTH_RESPONSE (2) by NewClient, for the rare server
+	// answering SERVERDATA_AUTH with a different type. An empty
+	// SERVERDATA_RESPONSE_VALUE is still discarded as the preamble, unless
+	// it's SERVERDATA_RESPONSE_VALUE (0) itself, in which case the first
+	// packet read is taken as the authorization's response.
+	AuthResponseType int32
+
+	// Reconnect and reauthorize when a command executed by ExecuteAsync
+	// loses the connection, before executing the commands queued after
+	// it, rather than failing each of them in turn. The command in flight
+	// as the connection dropped fails and isn't resent, as whether the
+	// server executed it is unknown: it's executed at most once. Should
+	// reconnecting fail, the next command fails with the error, and the
+	// one after it is tried with a reconnection again.
+	ReplayQueuedOnReconnect bool
+
+	// Take the packet read after a command as its response, rather than
+	// the packet mirroring its challenge, for servers that don't mirror
+	// challenges reliably. Responses are then correlated by order alone:
+	// should the server reorder or drop responses, or push unsolicited
+	// packets, commands receive the responses of others, undetected.
+	// Authorization still checks the challenge, which a server mirrors as
+	// -1 for a wrong password, as do responses split over several packets,
+	// such as CvarList's, whose end is marked by a separate challenge.
+	CorrelateByOrder bool
+
+	// Log commands to the Logger, flagged as dry runs, instead of sending
+	// them, for validating scripts without side effects. Execute and its
+	// variants, such as ExecuteAs, ExecuteInto and ExecuteAsync, as well
+	// as the helpers built on them, such as CvarList and Kick, return an
+	// empty SERVERDATA_RESPONSE_VALUE without touching the network; the
+	// helpers parsing responses may fail on it, and a persistent session's
+	// keep-alives no longer detect a lost connection. Authorization,
+	// ReadPacket and TailConsole aren't affected.
+	DryRun bool
+
+	// An optional decoder transcoding response bodies to UTF-8, for
+	// servers responding in a legacy encoding, such as Windows-1251, rather
+	// than UTF-8. Bodies are left as received if nil. Decoders aren't safe
+	// for concurrent use, so clients mustn't share one. See TextDecoder.
+	ResponseEncoding TextDecoder
+
+	// Resend a command retried by RetryOnChallengeMismatch with the
+	// challenge it was first sent with, rather than a fresh one, so a
+	// server or proxy echoing challenges can recognize and deduplicate the
+	// retry. The challenge is only reused within the retry of that one
+	// command; reusing it for another would let the stale response of one
+	// pass for the response of the other.
+	StableChallengeOnRetry bool
+
+	// The time after which the connection's closed if no command's been
+	// sent, to free the server's and the client's resources for rarely
+	// used clients, no limit if zero. The next command transparently
+	// reconnects and authorizes again with the password before it's sent.
+	// The keep-alives of a persistent session count as commands, so a
+	// client with one never idles; the two serve opposite intents.
+	IdleTimeout time.Duration
+
+	// Buffer the packets written, flushing them to the connection in one
+	// write once a response is read, rather than writing each as it's
+	// sent. Commands writing a single packet are unaffected, but those
+	// writing several, such as ExecuteMultiPacket with its end marker, are
+	// sent with fewer system calls.
+	BufferedWrites bool
+
+	// Flag responses to the Logger that are likely truncated, having a
+	// body close to Valve's limit per packet, as a hint to execute their
+	// commands with ExecuteMultiPacket. See Packet.LikelyTruncated.
+	WarnOnLikelyTruncation bool
+
+	// An optional classifier of response bodies, returning the error a
+	// command failed with, such as for a body reading "Permission denied",
+	// or nil if it succeeded, to encode a server's conventions for failed
+	// commands. It's applied to the responses of Execute and its variants,
+	// after StripColors; commands it fails return no response, so the
+	// error should describe the body. Responses are successful if nil.
+	ResultClassifier func(body string) error
+
+	// An optional generator of the challenge sent with each packet, such as
+	// a counter for deterministic tests, in place of the default, random
+	// challenges from crypto/rand. Challenges of 0 and -1 should be
+	// avoided, as servers use them for unsolicited packets and rejected
+	// authorizations. It's called with the client's command lock held, so
+	// it needn't be safe for concurrent use unless shared between clients,
+	// e.g. those of a Pool.
+	ChallengeFunc func() int32
+
+	// The initial capacity of the buffer response bodies are read into,
+	// 4096 bytes, Valve's limit per packet, if zero. It's reused for every
+	// response, and grown for larger ones.
+	ReadBufferSize int
+
+	// The number of reconnections the client may attempt, whether by a
+	// persistent session, ExecuteAsync, TailConsole or the helpers that
+	// reconnect, or to reopen a connection closed by IdleTimeout, no limit
+	// if zero. Once exhausted, reconnecting fails with
+	// ErrReconnectBudgetExhausted, as do commands needing to, until
+	// ResetReconnects is called, so a flapping server isn't hammered with
+	// connections forever. Connect itself isn't counted.
+	MaxReconnects int
+
+	// Read any packets the server pushes right after the connection's
+	// opened, before anything's written, such as a banner or MOTD, by
+	// Connect and on reconnecting, so they aren't taken for the first
+	// command's response. Connecting then waits briefly for the server
+	// to fall quiet. The banner's exposed by ConnectBanner.
+	ConsumeConnectBanner bool
+
+	// How strictly the null terminator and padding ending each response
+	// packet are checked, PaddingLenient by default. See PaddingValidation.
+	PaddingValidation PaddingValidation
+
+	// The time allowed for each fragment of a response split over several
+	// packets, such as ExecuteMultiPacket's, to follow the one before, as
+	// well as for the end marker, 2 seconds as set by NewClient, no limit
+	// but the command's Timeout if zero. Should the server stop sending
+	// them, the command returns the response assembled so far along with
+	// ErrIncompleteResponse; the connection should be reconnected, as the
+	// rest may still arrive.
+	FragmentTimeout time.Duration
+
+	// The number of times Connect retries dialing after the dial fails,
+	// such as while the server's still booting, waiting ConnectRetryDelay
+	// between attempts, no retries if zero. Only the dial's retried;
+	// reconnections, and failures after connecting, such as of
+	// RequireProtocolRange, aren't.
+	ConnectRetries int
+
+	// The time waited between the dial attempts of ConnectRetries.
+	ConnectRetryDelay time.Duration
+
+	// How strictly the helpers building commands, such as ExecuteArgs,
+	// Echo, SetCvar, Kick and Say, check them for anything that would run
+	// further commands, SanitizeEscape by default. See SanitizeCommand.
+	// Execute and its other variants send commands as given.
+	SanitizeMode SanitizeMode
+
+	// Read and discard a packet acknowledging each SERVERDATA_EXECCOMMAND,
+	// mirroring its challenge, before its response, for servers sending
+	// a short acknowledgement ahead of a command's output, which would
+	// otherwise be taken for the response. Authorization's preamble is
+	// handled separately, regardless.
+	ExpectAck bool
+
+	// The interval at which the client authorizes again with its password
+	// in the background, once authorized with Authorize, for servers
+	// expiring authorization after a fixed time regardless of activity; it
+	// should be shorter than that time. Renewals hold the command lock
+	// like any command, are logged to the Logger like Authorize, and their
+	// failures are sent on AuthRenewErrors, to be retried on the next
+	// interval. No renewals if zero. Disconnect stops them.
+	AuthRenewInterval time.Duration
+
+	// The time waited after writing a command before reading its response,
+	// for underpowered servers that answer reads made right away with
+	// partial or empty responses, no wait if zero. It applies to Execute
+	// and its variants, including those reading multi-packet responses
+	// and ExecuteInto, to Authorize, RoundTrip, DetectProtocol and SendRaw,
+	// but not to the fragments following a response's first, nor to
+	// ConformanceTest and Probe. It counts towards the command's Timeout
+	// and slows every command, so it's a last resort for servers still
+	// misbehaving once the response's framing is relied on, e.g. with
+	// ExecuteMultiPacket or FragmentTimeout.
+	ReadDelay time.Duration
+}
+
+// PaddingValidation is how strictly the null terminator and padding ending
+// response packets are checked.
+type PaddingValidation int
+
+const (
+	// Strip up to two trailing nulls, accepting packets however many of
+	// the terminator and padding servers send, including none.
+	PaddingLenient PaddingValidation = iota
+
+	// Require exactly PaddingSize trailing nulls, failing packets with
+	// ErrInvalidTermination otherwise, for conformance testing.
+	PaddingStrict
+
+	// Strip all trailing nulls, as LenientTrim does.
+	PaddingIgnore
+)
+
+// Limiter blocks until a command is allowed to be sent.
+type Limiter interface {
+	Wait(ctx context.Context) error
 }
 
 type header struct {
@@ -53,159 +454,1558 @@ type header struct {
 	headerType int32 // The type of request being sent.
 }
 
+// Size returns the size of the packet, as declared in its header.
+func (this header) Size() int32 {
+	return this.size
+}
+
+// Challenge returns the challenge the packet carries.
+func (this header) Challenge() int32 {
+	return this.challenge
+}
+
+// Type returns the packet's type, e.g. SERVERDATA_RESPONSE_VALUE.
+func (this header) Type() int32 {
+	return this.headerType
+}
+
 type Packet struct {
 	Header header // Packet header.
 	Body   string // Body of packet.
+
+	requestChallenge int32 // The challenge of the request answered, if any.
+}
+
+// ServerChallenge returns the challenge the server sent the packet with,
+// verbatim, as its header's. For a response, it's the challenge the server
+// echoed, which may differ from RequestChallenge with CorrelateByOrder,
+// for auditing servers that alter challenges.
+func (this Packet) ServerChallenge() int32 {
+	return this.Header.challenge
+}
+
+// RequestChallenge returns the challenge the client sent the request the
+// packet responds to with, or zero if the packet isn't a command's
+// response, such as one read with ReadPacket.
+func (this Packet) RequestChallenge() int32 {
+	return this.requestChallenge
+}
+
+// LikelyTruncated reports whether the packet's body is close enough to
+// Valve's limit of 4096 bytes per packet that the response was likely split
+// over several packets, of which this is only the first. Such responses
+// must be executed with ExecuteMultiPacket to be received in full.
+func (this Packet) LikelyTruncated() bool {
+	return len(this.Body) >= fragmentBodySize
+}
+
+// BodyBytes returns a copy of the packet's body as bytes, for bodies that
+// aren't text, such as responses to ExecuteBytes.
+func (this Packet) BodyBytes() []byte {
+	return []byte(this.Body)
 }
 
 // NewClient creates a new Client type, creating the connection
 // to the server specified by the host and port arguements. If
 // the connection fails, an error is returned.
 func NewClient(host string, port int, password string) (client *Client) {
-	client = &Client{Host: host, Port: port, password: password}
+	client = &Client{
+		Host:             host,
+		Port:             port,
+		password:         password,
+		PaddingSize:      packetPaddingSize,
+		LingerSeconds:    -1,
+		AuthResponseType: authResponse,
+		FragmentTimeout:  defaultFragmentTimeout,
+	}
 	return
 }
 
 func (this *Client) Connect() (err error) {
-	this.connection, err = net.Dial("tcp", fmt.Sprintf("%v:%v", this.Host, this.Port))
+	defer this.wrapError(&err)
+
+	if err = this.contextErr(); nil != err {
+		return
+	}
+
+	this.closing.Store(false)
+	this.stopIdle()
+
+	for attempt := 0; ; attempt++ {
+		if this.connection, err = this.dial(); nil == err {
+			break
+		} else if attempt >= this.ConnectRetries || this.closing.Load() {
+			return
+		}
+
+		time.Sleep(this.ConnectRetryDelay)
+	}
+
+	if err = this.opened(); nil != err {
+		this.connection.Close()
+		this.setActiveAddr("")
+	}
+
+	return
+}
+
+// opened forgets what was learned of the server over the client's previous
+// connection, which may have been to another of its addresses, and checks
+// the new one as required by RequireProtocolRange and ConsumeConnectBanner.
+func (this *Client) opened() (err error) {
+	this.sentinel.Store(int32(SentinelUnknown))
+	this.version.Store(nil)
+
+	if this.protocolRange {
+		if err = this.checkProtocol(); nil != err {
+			return
+		}
+	}
+
+	if this.ConsumeConnectBanner {
+		err = this.consumeBanner()
+	}
+
+	return
+}
+
+// dial opens a TCP connection to the client's server, or to the first of
+// its addresses accepting the connection, recording the active address.
+func (this *Client) dial() (conn net.Conn, err error) {
+	addresses := this.Addresses
+
+	if 0 == len(addresses) {
+		addresses = []string{fmt.Sprintf("%v:%v", this.Host, this.Port)}
+	}
+
+	for _, addr := range addresses {
+		if conn, err = net.DialTimeout("tcp", addr, this.DialTimeout); nil == err {
+			this.setActiveAddr(addr)
+
+			if nil != this.recorder {
+				conn = &recordingConn{conn, this.recorder}
+			}
+
+			return
+		}
+	}
+
+	this.setActiveAddr("")
+	translateTimeout(&err)
+
 	return
 }
 
+// ActiveAddr returns the address the client's connected to, or an empty
+// string if it's not connected.
+func (this *Client) ActiveAddr() string {
+	if addr := this.activeAddr.Load(); nil != addr {
+		return *addr
+	}
+
+	return ""
+}
+
+// setActiveAddr records the address the client's connected to, which is
+// read without the command lock, e.g. by the errors of commands unwinding.
+func (this *Client) setActiveAddr(addr string) {
+	this.activeAddr.Store(&addr)
+}
+
+// RemoteAddr returns the resolved address of the server the client's
+// connected to, after any failover between its Addresses, for logging and
+// correlating with firewall logs, or nil if it's not connected.
+func (this *Client) RemoteAddr() net.Addr {
+	if "" == this.ActiveAddr() || nil == this.connection {
+		return nil
+	}
+
+	return this.connection.RemoteAddr()
+}
+
+// LocalAddr returns the local address of the client's connection, or nil if
+// it's not connected.
+func (this *Client) LocalAddr() net.Addr {
+	if "" == this.ActiveAddr() || nil == this.connection {
+		return nil
+	}
+
+	return this.connection.LocalAddr()
+}
+
+// Conn returns the client's connection to the server, or nil if it never
+// connected, for socket options and integrations the package doesn't
+// expose. It's replaced on each Connect and reconnection, and closed by
+// Disconnect. Reading from or writing to it, or changing its deadlines,
+// concurrently with commands is unsafe and corrupts their exchange. While
+// recording, and after DetectProtocol, it's wrapped, so it isn't a
+// *net.TCPConn.
+func (this *Client) Conn() net.Conn {
+	return this.connection
+}
+
 func (this *Client) Disconnect() (err error) {
+	this.stopSession()
+	this.stopRenew()
+
+	if tcp := this.tcpConn(); nil != tcp && this.LingerSeconds >= 0 {
+		tcp.SetLinger(this.LingerSeconds)
+	}
+
+	this.stopWorker()
+	this.stopIdle()
+
 	if nil == this.connection {
 		return nil
 	}
 
-	return this.connection.Close()
+	this.setActiveAddr("")
+
+	// The connection's already closed should stopping the worker have
+	// closed it.
+	if err = this.connection.Close(); errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+
+	return
+}
+
+// tcpConn returns the client's underlying TCP connection, or nil if it's
+// not connected over TCP.
+func (this *Client) tcpConn() *net.TCPConn {
+	conn := this.connection
+
+	for {
+		switch wrapped := conn.(type) {
+		case *recordingConn:
+			conn = wrapped.Conn
+		case *peekConn:
+			conn = wrapped.Conn
+		default:
+			tcp, _ := conn.(*net.TCPConn)
+			return tcp
+		}
+	}
+}
+
+// Shutdown disconnects the client gracefully: new commands fail with
+// ErrClientShutdown, while the command in flight is allowed to complete
+// before the connection is closed. Should ctx expire first, the connection
+// is closed immediately, failing the command, and ctx's error is returned.
+func (this *Client) Shutdown(ctx context.Context) (err error) {
+	this.closing.Store(true)
+
+	idle := make(chan struct{})
+
+	go func() {
+		this.mutex.Lock()
+		this.mutex.Unlock()
+		close(idle)
+	}()
+
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		err = ctx.Err()
+
+		if nil != this.connection {
+			this.connection.Close()
+		}
+	}
+
+	if disconnectErr := this.Disconnect(); nil == err {
+		err = disconnectErr
+	}
+
+	return
 }
 
 // Authorize calls Send with the appropriate command type and the provided
 // password.  The response packet is returned if authorization is successful
 // or a potential error.
 func (this *Client) Authorize() (response *Packet, err error) {
-	if response, err = this.send(auth, this.password); nil == err {
-		if response.Header.headerType == authResponse {
-			this.authorized = true
-		} else {
-			err = ErrFailedAuthorization
-			response = nil
+	if this.AllowTextAuth {
+		var prompted bool
+
+		if response, prompted, err = this.authorizeText(); prompted || nil != err {
 			return
 		}
 	}
 
+	if response, err = this.send(auth, this.password); nil == err && response.Header.headerType != this.AuthResponseType {
+		err = ErrFailedAuthorization
+		response = nil
+		this.wrapError(&err)
+	}
+
+	// Whether or not the server reflects a body, the challenge decides
+	// success; a failed authorization, e.g. mirrored with a challenge of
+	// -1 for a wrong password, revokes any earlier one.
+	this.setAuthorized(nil == err, false)
+
+	if nil == err {
+		this.startRenew()
+	}
+
 	return
 }
 
-// Execute calls Send with the appropriate command type and the provided
-// command.  The response packet is returned if the command executed successfully
-// or a potential error.
-func (this *Client) Execute(command string) (response *Packet, err error) {
-	return this.send(exec, command)
+// Authorized reports whether the client's authorized over its current
+// connection, waiting for the command in flight, if any.
+func (this *Client) Authorized() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.authorized
 }
 
-// NewPacket returns a pointer to a new Packet type.
-func newPacket(challenge, typ int32, body string) (packet *Packet) {
-	size := int32(len([]byte(body)) + int(packetHeaderSize+packetPaddingSize))
-	return &Packet{header{size, challenge, typ}, body}
+// setAuthorized records whether the client's authorized, and whether with
+// a password read by AuthorizeReader, under the command lock.
+func (this *Client) setAuthorized(authorized bool, secret bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.authorized = authorized
+	this.secretAuth = secret
 }
 
-// Sends accepts the commands type and its string to execute to the clients server,
-// creating a packet with a random challenge id for the server to mirror,
-// and compiling its payload bytes in the appropriate order. The resonse is
-// decompiled from its bytes into a Packet type for return. An error is returned
-// if send fails.
-func (this *Client) send(typ int32, command string) (response *Packet, err error) {
-	if typ != auth && !this.authorized {
-		err = ErrUnauthorizedRequest
+// AuthorizeReader authorizes the client like Authorize, but with the
+// password read from r rather than the one the client was created with,
+// for passwords handed over by a secrets manager. The password is read
+// into a buffer of at most maxPasswordLength bytes, trailing line breaks
+// trimmed, and is written straight into the auth packet's payload without
+// becoming a string; both buffers are zeroed once the packet is sent.
+// Longer passwords fail with ErrPasswordTooLong. Text authorization isn't
+// attempted, regardless of AllowTextAuth.
+//
+// As the password isn't kept, reconnections don't authorize the client
+// again, and it isn't renewed every AuthRenewInterval: once reconnected,
+// commands fail with ErrUnauthorizedRequest until AuthorizeReader's called
+// again.
+func (this *Client) AuthorizeReader(r io.Reader) (response *Packet, err error) {
+	defer this.wrapError(&err)
+
+	// Room for the longest password followed by a line break.
+	password := make([]byte, maxPasswordLength+2)
+	defer clear(password)
+
+	var n int
+
+	if n, err = io.ReadFull(r, password); io.EOF == err || io.ErrUnexpectedEOF == err {
+		err = nil
+	} else if nil != err {
 		return
+	} else if more, _ := r.Read(make([]byte, 1)); 0 != more {
+		return nil, fmt.Errorf("%w Limit is %d bytes.", ErrPasswordTooLong, maxPasswordLength)
 	}
 
-	// Create a random challenge for the server to mirror in its response.
-	var challenge int32
-	binary.Read(rand.Reader, binary.LittleEndian, &challenge)
+	if n = len(bytes.TrimRight(password[:n], "\r\n")); maxPasswordLength < n {
+		return nil, fmt.Errorf("%w Limit is %d bytes.", ErrPasswordTooLong, maxPasswordLength)
+	}
 
-	// Create the packet from the challenge, typ and command
-	// and compile it to its byte payload
-	packet := newPacket(challenge, typ, command)
-	payload, err := packet.compile()
+	if response, err = this.authorizeSecret(password[:n]); nil == err && response.Header.headerType != this.AuthResponseType {
+		err = ErrFailedAuthorization
+		response = nil
+	}
 
-	var n int
+	this.setAuthorized(nil == err, true)
 
-	if nil != err {
+	return
+}
+
+// authorizeSecret sends SERVERDATA_AUTH with the password copied into the
+// compiled payload, zeroing the payload once it's written.
+func (this *Client) authorizeSecret(password []byte) (response *Packet, err error) {
+	if err = this.lock(); nil != err {
 		return
-	} else if n, err = this.connection.Write(payload); nil != err {
+	}
+	defer this.mutex.Unlock()
+
+	if err = this.throttle(); nil != err {
 		return
-	} else if n != len(payload) {
-		err = ErrInvalidWrite
+	} else if err = this.checkPaddingSize(); nil != err {
 		return
 	}
 
-	var header header
+	defer this.trackCommand(auth, "", time.Now(), &response, &err)
 
-	if err = binary.Read(this.connection, binary.LittleEndian, &header.size); nil != err {
-		return
-	} else if err = binary.Read(this.connection, binary.LittleEndian, &header.challenge); nil != err {
+	// Compile the packet with an empty body sized for the password,
+	// leaving null bytes in its place to copy the password over.
+	packet := newPacket(this.newChallenge(), auth, "", this.PaddingSize)
+	packet.Header.size += int32(len(password))
+
+	var payload []byte
+
+	if payload, err = packet.compile(); nil != err {
 		return
-	} else if err = binary.Read(this.connection, binary.LittleEndian, &header.headerType); nil != err {
+	}
+	defer clear(payload)
+
+	copy(payload[4+packetHeaderSize:], password)
+
+	// Written unbuffered, so no copy of the password's left in the write
+	// buffer.
+	if err = this.writePayload(payload, len(password), false); nil != err {
 		return
 	}
 
-	if packet.Header.headerType == auth && header.headerType == responseValue {
-		// Discard, empty SERVERDATA_RESPOSE_VALUE from authorization.
-		this.connection.Read(make([]byte, header.size-packetHeaderSize))
+	return this.readResponse(packet)
+}
+
+// Execute calls Send with the appropriate command type and the provided
+// command.  The response packet is returned if the command executed successfully
+// or a potential error.
+func (this *Client) Execute(command string) (response *Packet, err error) {
+	return this.send(exec, command)
+}
 
-		// Reread the packet header.
-		if err = binary.Read(this.connection, binary.LittleEndian, &header.size); nil != err {
-			return
-		} else if err = binary.Read(this.connection, binary.LittleEndian, &header.challenge); nil != err {
-			return
-		} else if err = binary.Read(this.connection, binary.LittleEndian, &header.headerType); nil != err {
-			return
+// ExecuteAs executes the command like Execute, but sends it with the
+// given packet type rather than SERVERDATA_EXECCOMMAND. Valve's protocol
+// defines the types:
+//
+//	3 SERVERDATA_AUTH
+//	2 SERVERDATA_EXECCOMMAND, and SERVERDATA_AUTH_RESPONSE in responses
+//	0 SERVERDATA_RESPONSE_VALUE
+//
+// Any other type is non-standard, and whether and how it's answered
+// depends on the server. Types other than SERVERDATA_AUTH still require
+// the client to be authorized.
+func (this *Client) ExecuteAs(typ int32, command string) (response *Packet, err error) {
+	return this.send(typ, command)
+}
+
+// ExecuteBytes executes the command like ExecuteAs, but with a body of
+// arbitrary bytes, for mods accepting binary commands. The body is sent
+// as is, not null terminated beyond the packet's padding, so it may
+// contain nulls; its response's body is available as bytes through
+// BodyBytes. Disable AppendNewline, StripColors, ThrottlePatterns and
+// LenientTrim, which treat bodies as text, to exchange binary bodies
+// unaltered.
+func (this *Client) ExecuteBytes(typ int32, body []byte) (response *Packet, err error) {
+	return this.send(typ, string(body))
+}
+
+// ExecuteMultiPacket executes the command like Execute, but assembles a
+// response the server splits over several packets, such as that of
+// cvarlist or a status with many players. Each command is followed by a
+// second, empty packet marking the end of its response, which costs an
+// extra round trip; ExecuteDetectFragmented tells whether it's needed.
+// Servers not supporting the packet are detected, see SentinelSupport.
+func (this *Client) ExecuteMultiPacket(command string) (response *Packet, err error) {
+	return this.sendMultiPacket(command, nil)
+}
+
+// ExecuteTo executes the command like ExecuteMultiPacket, additionally
+// writing the body of each of the response's packets to w as it's read,
+// for persisting large outputs without waiting for the whole response.
+// The bodies are written as received, before ResponseEncoding, StripColors
+// and ResultClassifier are applied to the returned response, so w may
+// have been written to even if the command fails. Should writing to w
+// fail, the command fails with ErrResponseWrite wrapping the error; the
+// connection should be reconnected, as the rest of the response remains
+// unread.
+func (this *Client) ExecuteTo(command string, w io.Writer) (response *Packet, err error) {
+	return this.sendMultiPacket(command, func(body string) (bool, error) {
+		if _, err := io.WriteString(w, body); nil != err {
+			return false, fmt.Errorf("%w %w", ErrResponseWrite, err)
 		}
-	}
 
-	if header.challenge != packet.Header.challenge {
-		err = ErrInvalidChallenge
+		return true, nil
+	})
+}
+
+// ExecuteLines executes the command like ExecuteMultiPacket, splitting the
+// response's body into its lines, ended by either "\n" or "\r\n". Trailing
+// nulls and the final line break are trimmed, so no empty last line's
+// returned; an empty body has no lines.
+func (this *Client) ExecuteLines(command string) (lines []string, err error) {
+	var response *Packet
+
+	if response, err = this.sendMultiPacket(command, nil); nil != err {
 		return
 	}
 
-	body := make([]byte, header.size-packetHeaderSize)
+	return splitLines(response.Body), nil
+}
 
-	n, err = this.connection.Read(body)
+// ExecuteLimited executes the command like ExecuteLines, but returns only
+// its first maxLines lines, reporting whether any were left out, for
+// commands producing more output than wanted, such as for a UI showing
+// its first lines. Fragments of the response following those holding the
+// first maxLines lines are read, to keep the connection in step, but
+// discarded rather than assembled. ThrottlePatterns and ResultClassifier
+// only see the lines assembled. A negative maxLines is taken as zero.
+func (this *Client) ExecuteLimited(command string, maxLines int) (lines []string, truncated bool, err error) {
+	var response *Packet
 
-	if nil != err {
-		return
-	} else if n != len(body) {
-		err = ErrInvalidRead
-		return
+	read := 0
+	maxLines = max(maxLines, 0)
+
+	sink := func(body string) (bool, error) {
+		if read >= maxLines {
+			truncated = truncated || "" != body
+			return false, nil
+		}
+
+		read += strings.Count(body, "\n")
+
+		return true, nil
+	}
+
+	if response, err = this.sendMultiPacket(command, sink); nil != err {
+		return nil, false, err
 	}
 
-	response = new(Packet)
-	response.Header = header
-	response.Body = strings.TrimRight(string(body), terminationSequence)
+	if lines = splitLines(response.Body); len(lines) > maxLines {
+		lines, truncated = lines[:maxLines], true
+	}
 
 	return
 }
 
-// Compile converts a packets header and body into its approriate
-// byte array payload, returning an error if the binary packages
-// Write method fails to write the header bytes in their little
-// endian byte order.
-func (this Packet) compile() (payload []byte, err error) {
-	var size int32 = this.Header.size
-	var buffer bytes.Buffer
-	var padding [packetPaddingSize]byte
+// ExecuteMatch executes the command like Execute, returning the submatches
+// of the pattern's first match in the response's body, one per capturing
+// group, for extracting values from any command's output without a parser
+// of its own. ErrNoMatch is returned if the body doesn't match. The body's
+// matched as a whole, so ^ and $ match at its start and end, unless the
+// pattern's compiled with the m flag, as in (?m)^hostname: (.*)$, to match
+// at each line's, and . doesn't match line breaks, unless with the s flag.
+func (this *Client) ExecuteMatch(command string, re *regexp.Regexp) (submatches []string, err error) {
+	var response *Packet
 
-	if err = binary.Write(&buffer, binary.LittleEndian, &size); nil != err {
-		return
-	} else if err = binary.Write(&buffer, binary.LittleEndian, &this.Header.challenge); nil != err {
-		return
-	} else if err = binary.Write(&buffer, binary.LittleEndian, &this.Header.headerType); nil != err {
+	if response, err = this.Execute(command); nil != err {
 		return
 	}
 
-	buffer.WriteString(this.Body)
-	buffer.Write(padding[:])
+	match := re.FindStringSubmatch(response.Body)
+
+	if nil == match {
+		return nil, fmt.Errorf("%w Pattern is %s.", ErrNoMatch, re)
+	}
+
+	return match[1:], nil
+}
+
+// splitLines splits the body into its lines, as ExecuteLines does.
+func splitLines(body string) []string {
+	body = strings.TrimRight(body, terminationSequence)
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	body = strings.TrimSuffix(body, "\n")
 
-	return buffer.Bytes(), nil
+	if "" == body {
+		return nil
+	}
+
+	return strings.Split(body, "\n")
+}
+
+// ExecuteDetectFragmented executes the command like Execute, additionally
+// reporting whether the response is likely split over several packets, as
+// its first packet's body reaches Valve's limit per packet. Only the first
+// packet is returned; the remaining fragments are discarded to keep the
+// connection aligned. Fragmented commands should be executed with
+// ExecuteMultiPacket instead.
+func (this *Client) ExecuteDetectFragmented(command string) (response *Packet, fragmented bool, err error) {
+	defer this.wrapError(&err)
+
+	if this.DryRun {
+		return this.dryRun(exec, command), false, nil
+	}
+
+	if err = this.lock(); nil != err {
+		return
+	}
+	defer this.mutex.Unlock()
+
+	if err = this.throttle(); nil != err {
+		return
+	}
+
+	defer this.trackCommand(exec, command, time.Now(), &response, &err)
+
+	if response, err = this.exchange(exec, command); nil != err {
+		return
+	}
+
+	if fragmented = response.LikelyTruncated(); fragmented {
+		if err = this.drain(); nil != err {
+			return nil, false, err
+		}
+	}
+
+	response, err = this.inspect(response)
+
+	return
+}
+
+// ReadPacket reads a single packet from the connection without sending a
+// request, for listening to packets the server pushes unsolicited, such as
+// logs or events. It holds the client's command lock while waiting, so
+// commands block until a packet arrives. Conversely, a packet pushed
+// while a command is awaiting its response is read as that response,
+// failing the command with ErrInvalidChallenge; callers mixing the two
+// should not issue commands while the server may push packets.
+func (this *Client) ReadPacket() (packet *Packet, err error) {
+	defer this.wrapError(&err)
+
+	if err = this.lock(); nil != err {
+		return
+	}
+	defer this.mutex.Unlock()
+
+	return this.readPacket()
+}
+
+// ExecuteInto executes the command like Execute, but reads the response
+// body into buf rather than allocating a new Packet, returning the length
+// of the body less its null terminators. Should buf be too small, it's
+// filled with the start of the body, the rest is discarded, and the length
+// buf needs to hold the whole body is returned with io.ErrShortBuffer.
+// MaxResponseBytes applies as with Execute, but the body's bytes are left
+// as read: ResponseEncoding, StripColors, ThrottlePatterns and
+// ResultClassifier, which work on the body as a string, aren't applied.
+func (this *Client) ExecuteInto(command string, buf []byte) (n int, err error) {
+	defer this.wrapError(&err)
+
+	if this.DryRun {
+		this.dryRun(exec, command)
+		return 0, nil
+	}
+
+	defer translateTimeout(&err)
+
+	if err = this.lock(); nil != err {
+		return
+	}
+	defer this.mutex.Unlock()
+
+	if !this.authorized && !this.AllowUnauthorizedExec {
+		err = ErrUnauthorizedRequest
+		return
+	} else if err = this.checkPaddingSize(); nil != err {
+		return
+	} else if err = this.throttle(); nil != err {
+		return
+	}
+
+	defer this.trackCommand(exec, command, time.Now(), nil, &err)
+
+	packet := newPacket(this.newChallenge(), exec, this.commandBody(exec, command), this.PaddingSize)
+
+	var header header
+
+	if err = this.writePacket(packet); nil != err {
+		return
+	} else if err = this.awaitResponse(); nil != err {
+		return
+	} else if err = this.readAck(packet); nil != err {
+		return
+	} else if header, err = this.readHeader(); nil != err {
+		return
+	}
+
+	size := int(header.size - packetHeaderSize)
+	n = size
+
+	if size < 0 {
+		return 0, ErrInvalidRead
+	} else if 0 == size {
+		return 0, nil
+	} else if this.MaxResponseBytes > 0 && size > this.MaxResponseBytes+int(this.PaddingSize) {
+		return 0, fmt.Errorf("%w Limit is %d bytes.", ErrResponseTooLarge, this.MaxResponseBytes)
+	}
+
+	if size > len(buf) {
+		n = len(buf)
+	}
+
+	if _, err = io.ReadFull(this.connection, buf[:n]); nil != err {
+		return
+	} else if _, err = io.CopyN(io.Discard, this.connection, int64(size-n)); nil != err {
+		return
+	}
+
+	if !this.correlates(packet.Header, header) {
+		return 0, ErrInvalidChallenge
+	} else if n < size {
+		return size, io.ErrShortBuffer
+	}
+
+	var body []byte
+
+	if body, err = this.trimBody(buf[:n]); nil != err {
+		return 0, err
+	}
+
+	n = len(body)
+
+	return
+}
+
+// NewPacket returns a pointer to a new Packet type with the challenge, type
+// and body, padded as per Valve's spec, for sending with RoundTrip.
+func NewPacket(challenge, typ int32, body string) *Packet {
+	return newPacket(challenge, typ, body, packetPaddingSize)
+}
+
+// newPacket returns a pointer to a new Packet type, its body followed
+// by padding null bytes.
+func newPacket(challenge, typ int32, body string, padding int32) (packet *Packet) {
+	size := int32(len([]byte(body)) + int(packetHeaderSize+padding))
+	return &Packet{Header: header{size, challenge, typ}, Body: body}
+}
+
+// commandBody returns the body of a packet of the type sending command,
+// newline terminated for SERVERDATA_EXECCOMMAND if AppendNewline is set.
+func (this *Client) commandBody(typ int32, command string) string {
+	if typ == exec && this.AppendNewline {
+		return command + "\n"
+	}
+
+	return command
+}
+
+// Send executes the command, retrying it once on ErrInvalidChallenge when
+// RetryOnChallengeMismatch is set. A mismatch is most often caused by a
+// stray or late response to an earlier command, so the connection is
+// drained of it before the command is sent again.
+func (this *Client) send(typ int32, command string) (response *Packet, err error) {
+	defer this.wrapError(&err)
+
+	if this.DryRun && typ != auth {
+		return this.dryRun(typ, command), nil
+	}
+
+	if err = this.lock(); nil != err {
+		return
+	}
+	defer this.mutex.Unlock()
+
+	if err = this.throttle(); nil != err {
+		return
+	}
+
+	defer this.trackCommand(typ, command, time.Now(), &response, &err)
+
+	challenge := this.newChallenge()
+	response, err = this.exchangeWith(challenge, typ, command)
+
+	if ErrInvalidChallenge == err && typ != auth && this.RetryOnChallengeMismatch {
+		if !this.StableChallengeOnRetry {
+			challenge = this.newChallenge()
+		}
+
+		if err = this.drain(); nil == err {
+			response, err = this.exchangeWith(challenge, typ, command)
+		}
+	}
+
+	if nil == err {
+		response, err = this.inspect(response)
+	}
+
+	return
+}
+
+// Exchange accepts the commands type and its string to execute to the clients server,
+// creating a packet with a random challenge id for the server to mirror,
+// and compiling its payload bytes in the appropriate order. The resonse is
+// decompiled from its bytes into a Packet type for return. An error is returned
+// if send fails.
+func (this *Client) exchange(typ int32, command string) (response *Packet, err error) {
+	return this.exchangeWith(this.newChallenge(), typ, command)
+}
+
+// exchangeWith exchanges the command like exchange, but with the given
+// challenge rather than a random one.
+func (this *Client) exchangeWith(challenge, typ int32, command string) (response *Packet, err error) {
+	if typ != auth && !this.authorized && !this.AllowUnauthorizedExec {
+		err = ErrUnauthorizedRequest
+		return
+	} else if err = this.checkPaddingSize(); nil != err {
+		return
+	}
+
+	// Create the packet from the challenge, typ and command for the
+	// server to mirror in its response.
+	return this.roundTrip(newPacket(challenge, typ, this.commandBody(typ, command), this.PaddingSize))
+}
+
+// RoundTrip writes the fully formed request packet, with its challenge and
+// type chosen by the caller, and reads the response mirroring its
+// challenge. Nothing beyond framing and challenge correlation is done: the
+// client needn't be authorized, commands aren't throttled and responses
+// aren't retried or inspected. It's serialized with the client's other
+// commands.
+func (this *Client) RoundTrip(request *Packet) (response *Packet, err error) {
+	defer this.wrapError(&err)
+
+	if err = this.lock(); nil != err {
+		return
+	}
+	defer this.mutex.Unlock()
+
+	return this.roundTrip(request)
+}
+
+// roundTrip implements RoundTrip for callers already holding the command
+// lock.
+func (this *Client) roundTrip(packet *Packet) (response *Packet, err error) {
+	if err = this.writePacket(packet); nil != err {
+		return
+	} else if err = this.awaitResponse(); nil != err {
+		return
+	}
+
+	return this.readResponse(packet)
+}
+
+// readResponse reads the response to the packet just written, discarding
+// the empty SERVERDATA_RESPONSE_VALUE preceding an authorization response
+// and checking the mirrored challenge.
+func (this *Client) readResponse(packet *Packet) (response *Packet, err error) {
+	if err = this.readAck(packet); nil != err {
+		return
+	} else if response, err = this.readPacket(); nil != err {
+		return
+	}
+
+	if packet.Header.headerType == auth && !this.SkipAuthPreambleDiscard {
+		preamble := false
+
+		// Discard, empty SERVERDATA_RESPOSE_VALUE from authorization,
+		// and reread the packet until the SERVERDATA_AUTH_RESPONSE,
+		// unless it can't be told from the response.
+		for response.Header.headerType == responseValue && "" == response.Body && this.AuthResponseType != responseValue {
+			preamble = true
+
+			if response, err = this.readPacket(); nil != err {
+				return
+			}
+		}
+
+		// Some servers send it after the SERVERDATA_AUTH_RESPONSE instead,
+		// if at all, so it's discarded should it precede the next response.
+		this.latePreamble = !preamble
+		this.authChallenge = packet.Header.challenge
+	}
+
+	if !this.correlates(packet.Header, response.Header) {
+		err = ErrInvalidChallenge
+		response = nil
+	} else {
+		response.requestChallenge = packet.Header.challenge
+	}
+
+	return
+}
+
+// readAck reads and discards the acknowledgement preceding the response to
+// a SERVERDATA_EXECCOMMAND, should the client expect one, checking that it
+// mirrors the command's challenge.
+func (this *Client) readAck(packet *Packet) (err error) {
+	if !this.ExpectAck || packet.Header.headerType != exec {
+		return
+	}
+
+	var ack *Packet
+
+	if ack, err = this.readPacket(); nil != err {
+		return
+	} else if !this.correlates(packet.Header, ack.Header) {
+		err = ErrInvalidChallenge
+	}
+
+	return
+}
+
+// dryRun logs the command in place of sending it, returning an empty
+// SERVERDATA_RESPONSE_VALUE as its response.
+func (this *Client) dryRun(typ int32, command string) *Packet {
+	var err error
+
+	this.trackCommand(typ, command, time.Now(), nil, &err)
+
+	return newPacket(0, responseValue, "", packetPaddingSize)
+}
+
+// correlates reports whether the response answers the request: whether
+// it mirrors the request's challenge, or, with CorrelateByOrder, simply
+// follows it, unless authorizing.
+func (this *Client) correlates(request, response header) bool {
+	if this.CorrelateByOrder && request.headerType != auth {
+		return true
+	}
+
+	return response.challenge == request.challenge
+}
+
+// fragmentSink receives the body of each fragment of a response as it's
+// read, returning whether it's kept in the assembled response, or an error
+// failing the command.
+type fragmentSink func(body string) (keep bool, err error)
+
+// sendMultiPacket executes the command like send, but assembles a response
+// the server split over several packets. An empty SERVERDATA_RESPONSE_VALUE
+// is sent directly after the command; the server mirrors it only once every
+// fragment of the command's response has been written, marking the end.
+// Each fragment's body is passed to sink, if not nil, as it's read.
+func (this *Client) sendMultiPacket(command string, sink fragmentSink) (response *Packet, err error) {
+	defer this.wrapError(&err)
+
+	if this.DryRun {
+		return this.dryRun(exec, command), nil
+	}
+
+	if err = this.lock(); nil != err {
+		return
+	}
+	defer this.mutex.Unlock()
+
+	if err = this.throttle(); nil != err {
+		return
+	}
+
+	defer this.trackCommand(exec, command, time.Now(), &response, &err)
+
+	if !this.authorized && !this.AllowUnauthorizedExec {
+		err = ErrUnauthorizedRequest
+		return
+	} else if err = this.checkPaddingSize(); nil != err {
+		return
+	}
+
+	packet := newPacket(this.newChallenge(), exec, this.commandBody(exec, command), this.PaddingSize)
+	sentinel := newPacket(this.newChallenge(), responseValue, "", this.PaddingSize)
+	useSentinel := SentinelUnsupported != this.SentinelSupport()
+
+	if err = this.writePacket(packet); nil != err {
+		return
+	} else if useSentinel {
+		if err = this.writePacket(sentinel); nil != err {
+			return
+		}
+	}
+
+	if err = this.awaitResponse(); nil != err {
+		return
+	} else if err = this.readAck(packet); nil != err {
+		return
+	}
+
+	var body bytes.Buffer
+	var fragment *Packet
+	var index int
+
+	// Only errors reading the response tell of the sentinel's support,
+	// not those of sink, e.g. ExecuteTo's writer failing.
+	var readErr error
+
+	if useSentinel {
+		defer func() {
+			if nil != readErr {
+				this.detectSentinel(index, readErr)
+			}
+		}()
+	}
+
+	for ; ; index++ {
+		bounded, quiet := false, false
+
+		if useSentinel {
+			bounded = 0 < index && this.boundFragment()
+		} else if 0 < index {
+			// Without a sentinel, only a fragment close to the limit per
+			// packet may be followed by another.
+			if !fragment.LikelyTruncated() {
+				break
+			}
+
+			quiet = true
+			this.boundQuiet()
+		}
+
+		fragment, err = this.readPacket()
+		readErr = err
+
+		if quiet && errors.Is(err, ErrTimeout) {
+			err = nil
+			break
+		} else if bounded && errors.Is(err, ErrTimeout) {
+			response = newPacket(packet.Header.challenge, responseValue, body.String(), packetPaddingSize)
+			response.requestChallenge = packet.Header.challenge
+
+			if response, err = this.inspect(response); nil == err {
+				err = ErrIncompleteResponse
+			}
+
+			return
+		} else if nil != err {
+			return
+		}
+
+		if useSentinel && fragment.Header.challenge == sentinel.Header.challenge {
+			break
+		} else if fragment.Header.challenge != packet.Header.challenge {
+			err = ErrInvalidChallenge
+			readErr = err
+			return
+		}
+
+		if nil != this.OnFragment {
+			this.OnFragment(index, fragment.Body)
+		}
+
+		if nil != sink {
+			var keep bool
+
+			if keep, err = sink(fragment.Body); nil != err {
+				return
+			} else if !keep {
+				continue
+			}
+		}
+
+		if this.MaxResponseBytes > 0 && body.Len()+len(fragment.Body) > this.MaxResponseBytes {
+			err = fmt.Errorf("%w Limit is %d bytes.", ErrResponseTooLarge, this.MaxResponseBytes)
+			return
+		}
+
+		body.WriteString(fragment.Body)
+	}
+
+	this.connection.SetReadDeadline(this.deadline)
+
+	// Source servers follow the mirrored sentinel with a second packet
+	// carrying 0x00000100, which must be consumed to keep the stream aligned.
+	if useSentinel {
+		if _, err = this.readPacket(); nil != err {
+			readErr = err
+			return
+		}
+
+		this.sentinel.Store(int32(SentinelSupported))
+	}
+
+	// Every fragment mirrored the command's challenge, so it's the one the
+	// server echoed too.
+	response = newPacket(packet.Header.challenge, responseValue, body.String(), packetPaddingSize)
+	response.requestChallenge = packet.Header.challenge
+
+	return this.inspect(response)
+}
+
+// inspect applies the client's response handling to a command's response,
+// transcoding its body with the client's ResponseEncoding, failing it with
+// ErrRateLimited if its body matches any of the client's throttle patterns,
+// stripping its color codes if configured to, and failing it with the error
+// of the client's ResultClassifier.
+func (this *Client) inspect(response *Packet) (*Packet, error) {
+	if err := this.decodeBody(response); nil != err {
+		return nil, err
+	}
+
+	body := strings.ToLower(response.Body)
+
+	for _, pattern := range this.ThrottlePatterns {
+		if strings.Contains(body, strings.ToLower(pattern)) {
+			return nil, ErrRateLimited
+		}
+	}
+
+	if this.StripColors {
+		response.Body = StripColorCodes(response.Body)
+	}
+
+	if nil != this.ResultClassifier {
+		if err := this.ResultClassifier(response.Body); nil != err {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// throttle waits on the client's limiter and minimum command interval,
+// then records the time the command is sent. Either wait's aborted once
+// the client's context is done.
+func (this *Client) throttle() (err error) {
+	ctx := this.context()
+
+	if nil != this.Limiter {
+		if err = this.Limiter.Wait(ctx); nil != err {
+			if nil != ctx.Err() {
+				err = this.contextErr()
+			}
+
+			return
+		}
+	}
+
+	if wait := this.MinCommandInterval - time.Since(this.lastCommand); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return this.contextErr()
+		case <-timer.C:
+		}
+	}
+
+	this.lastCommand = time.Now()
+
+	return
+}
+
+// wrapError annotates the error pointed to, if any, with the client's
+// Name, if set, and the address of its server, so errors from a fleet of
+// clients are self describing. The underlying error remains reachable
+// with errors.Is and errors.As.
+func (this *Client) wrapError(err *error) {
+	if nil == *err {
+		return
+	} else if "" != this.Name {
+		*err = fmt.Errorf("rcon %s (%s): %w", this.Name, this.addr(), *err)
+	} else {
+		*err = fmt.Errorf("rcon %s: %w", this.addr(), *err)
+	}
+}
+
+// addr returns the address the client's connected to, or otherwise the
+// addresses it connects to.
+func (this *Client) addr() string {
+	if addr := this.ActiveAddr(); "" != addr {
+		return addr
+	} else if 0 != len(this.Addresses) {
+		return strings.Join(this.Addresses, ",")
+	}
+
+	return fmt.Sprintf("%v:%v", this.Host, this.Port)
+}
+
+// lock acquires the client's command lock, failing with ErrClientShutdown
+// if the client's shutting down. A connection IdleTimeout closed is
+// reopened and authorized again, failing with the error should it not be,
+// and with ErrNotConnected should the client have no connection.
+func (this *Client) lock() error {
+	if err := this.lockConnection(); nil != err {
+		return err
+	}
+
+	// Reopen the connection should it have been closed for being idle.
+	if this.idleClosed.Swap(false) {
+		if err := this.redial(); nil != err {
+			this.idleClosed.Store(true)
+			this.mutex.Unlock()
+			return err
+		}
+	}
+
+	// A failed reconnection leaves the client without a connection.
+	if nil == this.connection {
+		this.mutex.Unlock()
+		return ErrNotConnected
+	}
+
+	this.armIdle()
+
+	return nil
+}
+
+// lockConnection takes the client's command lock like lock, but without
+// reopening an idle connection.
+func (this *Client) lockConnection() error {
+	this.mutex.Lock()
+
+	if this.closing.Load() {
+		this.mutex.Unlock()
+		return ErrClientShutdown
+	} else if err := this.contextErr(); nil != err {
+		this.mutex.Unlock()
+		return err
+	}
+
+	this.setDeadline()
+
+	return nil
+}
+
+// setDeadline bounds the command about to be run by the client's timeout,
+// or clears the connection's deadline if it has none.
+func (this *Client) setDeadline() {
+	if nil == this.connection {
+		return
+	} else if this.Timeout > 0 {
+		this.deadline = time.Now().Add(this.Timeout)
+	} else {
+		this.deadline = time.Time{}
+	}
+
+	this.connection.SetDeadline(this.deadline)
+}
+
+// boundFragment bounds reading the next fragment of a response by the
+// client's FragmentTimeout, unless the command's deadline is sooner,
+// reporting whether it is.
+func (this *Client) boundFragment() (bounded bool) {
+	if this.FragmentTimeout <= 0 {
+		return false
+	}
+
+	deadline := time.Now().Add(this.FragmentTimeout)
+
+	if bounded = this.deadline.IsZero() || deadline.Before(this.deadline); bounded {
+		this.connection.SetReadDeadline(deadline)
+	}
+
+	return
+}
+
+// checkPaddingSize validates the configured padding size, as a wrong size
+// corrupts the framing of every packet sent.
+func (this *Client) checkPaddingSize() error {
+	if this.PaddingSize < 1 || this.PaddingSize > packetPaddingSize {
+		return ErrInvalidPaddingSize
+	}
+
+	return nil
+}
+
+// writePacket compiles the packet and writes its payload to the connection.
+func (this *Client) writePacket(packet *Packet) (err error) {
+	var payload []byte
+
+	buf := getBuffer(0)
+	defer putBuffer(buf)
+
+	if payload, err = packet.compileInto(*buf); nil != err {
+		return
+	}
+
+	*buf = payload
+
+	return this.writePayload(payload, len(packet.Body), this.BufferedWrites)
+}
+
+// writePayload writes a compiled packet's payload to the connection, with
+// the null padding following its body of the given length replaced by the
+// client's padding bytes. Buffered, the payload's written to the client's
+// write buffer, flushed before the next read, rather than the connection.
+func (this *Client) writePayload(payload []byte, bodyLength int, buffered bool) (err error) {
+	defer translateTimeout(&err)
+
+	var n int
+
+	// Replace the null padding with the client's padding bytes.
+	padding := payload[4+packetHeaderSize+int32(bodyLength):]
+	copy(padding, this.PaddingBytes[:])
+
+	if buffered {
+		n, err = this.bufferedWriter().Write(payload)
+	} else if err = this.flush(); nil == err {
+		n, err = this.connection.Write(payload)
+	}
+	this.metrics.bytesSent.Add(int64(n))
+
+	if nil != err {
+		return
+	} else if n != len(payload) {
+		err = ErrInvalidWrite
+	}
+
+	return
+}
+
+// awaitResponse flushes the command just written and waits the client's
+// ReadDelay before its response is read.
+func (this *Client) awaitResponse() (err error) {
+	defer translateTimeout(&err)
+
+	if err = this.flush(); nil != err {
+		return
+	}
+
+	if 0 < this.ReadDelay {
+		time.Sleep(this.ReadDelay)
+	}
+
+	return
+}
+
+// bufferedWriter returns the client's write buffer, writing to its
+// current connection.
+func (this *Client) bufferedWriter() *bufio.Writer {
+	if nil == this.writer {
+		this.writer = bufio.NewWriter(this.connection)
+	} else if this.writerConn != this.connection {
+		this.writer.Reset(this.connection)
+	}
+
+	this.writerConn = this.connection
+
+	return this.writer
+}
+
+// readBuffer returns the client's buffer reused for reading bodies, empty,
+// allocating it at ReadBufferSize if it's not yet.
+func (this *Client) readBuffer() []byte {
+	if nil == this.reuseBuffer {
+		size := this.ReadBufferSize
+		if size <= 0 {
+			size = defaultReadBufferSize
+		}
+
+		this.reuseBuffer = make([]byte, 0, size)
+	}
+
+	return this.reuseBuffer[:0]
+}
+
+// flush writes the packets buffered with BufferedWrites to the connection.
+func (this *Client) flush() error {
+	if nil == this.writer || 0 == this.writer.Buffered() {
+		return nil
+	} else if this.writerConn != this.connection {
+		// Written before the connection was replaced.
+		this.writer.Reset(this.connection)
+		return nil
+	}
+
+	return this.writer.Flush()
+}
+
+// readPacket reads a single packet from the connection, decompiling its
+// header and trimming the null terminators from its body.
+func (this *Client) readPacket() (packet *Packet, err error) {
+	defer translateTimeout(&err)
+
+	var header header
+
+	if header, err = this.readHeader(); nil != err {
+		return
+	} else if header.size < packetHeaderSize {
+		err = ErrInvalidRead
+		return
+	} else if header.size == packetHeaderSize {
+		// Malformed, without even the null terminator, but seen in the
+		// wild; there's no body to read.
+		packet = &Packet{Header: header}
+		return
+	}
+
+	size := int64(header.size - packetHeaderSize)
+
+	if this.MaxResponseBytes > 0 && size > int64(this.MaxResponseBytes)+int64(this.PaddingSize) {
+		err = fmt.Errorf("%w Limit is %d bytes.", ErrResponseTooLarge, this.MaxResponseBytes)
+		return
+	}
+
+	// Read the body through a LimitReader into a buffer grown as it
+	// arrives, rather than one allocated at the size declared up front,
+	// so a server declaring a huge size can't have the client allocate it.
+	limited := io.LimitedReader{R: this.connection, N: size}
+	body := this.readBuffer()
+
+	for 0 < limited.N && nil == err {
+		var n int
+
+		body = slices.Grow(body, int(min(limited.N, 4096)))
+		n, err = limited.Read(body[len(body):cap(body)])
+		body = body[:len(body)+n]
+	}
+
+	// Keep the grown buffer for the next read, unless it's grown too large
+	// to keep.
+	if cap(body) <= maxPooledBuffer {
+		this.reuseBuffer = body[:0]
+	}
+
+	if io.EOF == err {
+		err = ErrInvalidRead
+		return
+	} else if nil != err {
+		return
+	}
+
+	if body, err = this.trimBody(body); nil != err {
+		return
+	}
+
+	packet = new(Packet)
+	packet.Header = header
+	packet.Body = string(body) // Copied, so the read buffer can be reused.
+
+	return
+}
+
+// trimBody strips the null terminator and padding from the end of a
+// packet's body as per the client's PaddingValidation. Strictly, exactly
+// PaddingSize bytes are stripped, failing with ErrInvalidTermination if
+// they aren't null.
+func (this *Client) trimBody(body []byte) ([]byte, error) {
+	if this.LenientTrim || PaddingIgnore == this.PaddingValidation {
+		return bytes.TrimRight(body, terminationSequence), nil
+	} else if PaddingStrict != this.PaddingValidation {
+		for i := 0; i < int(packetPaddingSize) && 0 < len(body) && 0 == body[len(body)-1]; i++ {
+			body = body[:len(body)-1]
+		}
+
+		return body, nil
+	}
+
+	padding := int(this.PaddingSize)
+
+	if len(body) < padding {
+		return nil, ErrInvalidTermination
+	}
+
+	for _, b := range body[len(body)-padding:] {
+		if 0 != b {
+			return nil, ErrInvalidTermination
+		}
+	}
+
+	return body[:len(body)-padding], nil
+}
+
+// readHeader reads a packet's header from the connection.
+func (this *Client) readHeader() (header header, err error) {
+	defer translateTimeout(&err)
+
+	if err = this.flush(); nil != err {
+		return
+	}
+
+	for {
+		if err = binary.Read(this.connection, binary.LittleEndian, &header.size); nil != err {
+			return
+		} else if err = binary.Read(this.connection, binary.LittleEndian, &header.challenge); nil != err {
+			return
+		} else if err = binary.Read(this.connection, binary.LittleEndian, &header.headerType); nil != err {
+			return
+		}
+
+		this.metrics.bytesReceived.Add(int64(4 + header.size))
+
+		late := this.latePreamble
+		this.latePreamble = false
+
+		if !late || header.challenge != this.authChallenge || header.headerType != responseValue ||
+			header.size > packetHeaderSize+this.PaddingSize {
+			return
+		}
+
+		// Discard the SERVERDATA_RESPONSE_VALUE sent late by authorization.
+		if _, err = io.CopyN(io.Discard, this.connection, int64(header.size-packetHeaderSize)); nil != err {
+			return
+		}
+	}
+}
+
+// drain discards any bytes already waiting on the connection, reading
+// until no more arrive within the drain timeout or the command's deadline
+// passes, which is kept for the rest of the command.
+func (this *Client) drain() (err error) {
+	buffer := make([]byte, 4096)
+
+	for nil == err {
+		this.boundQuiet()
+		_, err = this.connection.Read(buffer)
+	}
+
+	this.connection.SetReadDeadline(this.deadline)
+
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		err = nil
+	}
+
+	return
+}
+
+// newChallenge creates the challenge for the server to mirror with the
+// client's ChallengeFunc, as ReplayClient sets to send the challenges
+// recorded, or at random without one.
+func (this *Client) newChallenge() int32 {
+	if nil != this.ChallengeFunc {
+		return this.ChallengeFunc()
+	}
+
+	return newChallenge()
+}
+
+// newChallenge creates a random challenge for the server to mirror, other
+// than 0, which servers may use for unsolicited packets, and -1, with
+// which they reject authorization.
+func newChallenge() (challenge int32) {
+	for 0 == challenge || -1 == challenge {
+		binary.Read(rand.Reader, binary.LittleEndian, &challenge)
+	}
+
+	return
+}
+
+// Compile converts a packets header and body into its approriate
+// byte array payload, writing the header's fields in their little
+// endian byte order directly into a single, exactly sized, buffer.
+// An error is returned if the header's size is too small to hold
+// the body.
+func (this Packet) compile() (payload []byte, err error) {
+	return this.compileInto(nil)
+}
+
+// compileInto compiles the packet like compile, but into buf, should its
+// capacity suffice, rather than a new buffer.
+func (this Packet) compileInto(buf []byte) (payload []byte, err error) {
+	var size int32 = this.Header.size
+
+	if size-packetHeaderSize < int32(len(this.Body)) {
+		err = ErrInvalidPaddingSize
+		return
+	}
+
+	if cap(buf) < int(4+size) {
+		payload = make([]byte, 4+size)
+	} else {
+		payload = buf[:4+size]
+		clear(payload[12+len(this.Body):])
+	}
+
+	binary.LittleEndian.PutUint32(payload[0:], uint32(size))
+	binary.LittleEndian.PutUint32(payload[4:], uint32(this.Header.challenge))
+	binary.LittleEndian.PutUint32(payload[8:], uint32(this.Header.headerType))
+	copy(payload[12:], this.Body)
+
+	return
+}
+
+// The largest buffer returned to the buffer pool or kept for reading;
+// larger buffers, such as those of exceptionally large responses, are left
+// to be collected.
+const maxPooledBuffer = 64 << 10
+
+// Buffers for compiling packets, reused between commands to spare the
+// allocations.
+var buffers = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+// getBuffer returns a pooled buffer of length size.
+func getBuffer(size int) *[]byte {
+	buf := buffers.Get().(*[]byte)
+
+	if cap(*buf) < size {
+		*buf = make([]byte, size)
+	}
+
+	*buf = (*buf)[:size]
+
+	return buf
+}
+
+// putBuffer returns the buffer to the pool, unless it's too large to keep.
+func putBuffer(buf *[]byte) {
+	if cap(*buf) <= maxPooledBuffer {
+		buffers.Put(buf)
+	}
 }
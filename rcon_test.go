@@ -2,7 +2,13 @@ package rcon
 
 // Test assumes you have a local (or docker) running server, listening on 27015, with password "rconpassword"
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
 
 const hostname string = "localhost"
 const port int = 27015
@@ -79,3 +85,130 @@ func TestWrongPassword(t *testing.T) {
 func getNewClient() *Client {
 	return NewClient(hostname, port, pw)
 }
+
+func TestCompile(t *testing.T) {
+	payload, err := newPacket(0x01020304, exec, "status", packetPaddingSize).compile()
+	if nil != err {
+		t.Log("Expected no error during compile", err)
+		t.FailNow()
+	}
+
+	expected := []byte{
+		16, 0, 0, 0, // Size
+		4, 3, 2, 1, // Challenge
+		2, 0, 0, 0, // Type
+		's', 't', 'a', 't', 'u', 's', 0, 0, // Body and padding
+	}
+
+	if !bytes.Equal(payload, expected) {
+		t.Log("Unexpected payload", payload)
+		t.Fail()
+	}
+}
+
+func BenchmarkCompile(b *testing.B) {
+	packet := newPacket(42, exec, "status", packetPaddingSize)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		packet.compile()
+	}
+}
+
+// logEntries is a Logger collecting the entries logged.
+type logEntries []LogEntry
+
+func (this *logEntries) Log(entry LogEntry) {
+	*this = append(*this, entry)
+}
+
+func TestDryRun(t *testing.T) {
+	var entries logEntries
+
+	c := NewClient(hostname, port, pw)
+	c.DryRun = true
+	c.AppendNewline = true
+	c.Logger = &entries
+
+	response, err := c.Execute("changelevel de_dust2")
+	if nil != err {
+		t.Log("Expected no error during a dry run", err)
+		t.FailNow()
+	}
+
+	if "" != response.Body || 1 != len(entries) || !entries[0].DryRun || "changelevel de_dust2" != entries[0].Command || "changelevel de_dust2\n" != entries[0].Wire {
+		t.Log("Unexpected dry run", response, entries)
+		t.Fail()
+	}
+}
+
+func TestLikelyTruncated(t *testing.T) {
+	if (Packet{Body: "hostname: mock"}).LikelyTruncated() {
+		t.Log("Expected a short body not to be likely truncated")
+		t.Fail()
+	}
+
+	if !(Packet{Body: strings.Repeat("a", 4086)}).LikelyTruncated() {
+		t.Log("Expected a body at the packet limit to be likely truncated")
+		t.Fail()
+	}
+}
+
+func TestResultClassifier(t *testing.T) {
+	denied := errors.New("permission denied")
+
+	c := NewClient(hostname, port, pw)
+	c.ResultClassifier = func(body string) error {
+		if strings.HasPrefix(body, "Permission denied") {
+			return denied
+		}
+
+		return nil
+	}
+
+	if _, err := c.inspect(&Packet{Body: "Permission denied: sv_cheats"}); denied != err {
+		t.Log("Expected the classifier's error, got", err)
+		t.Fail()
+	}
+
+	if _, err := c.inspect(&Packet{Body: "sv_cheats = 0"}); nil != err {
+		t.Log("Expected no error for a successful body", err)
+		t.Fail()
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := map[string][]string{
+		"a\nb\n":         {"a", "b"},
+		"a\r\nb\r\n\x00": {"a", "b"},
+		"a\n\nb":         {"a", "", "b"},
+		"\n":             nil,
+		"":               nil,
+	}
+
+	for body, expected := range tests {
+		if lines := splitLines(body); !slices.Equal(lines, expected) {
+			t.Logf("Expected %q to split into %q, got %q", body, expected, lines)
+			t.Fail()
+		}
+	}
+}
+
+func BenchmarkReadPacket(b *testing.B) {
+	payload, _ := newPacket(42, responseValue, strings.Repeat("hostname: mock\n", 64), packetPaddingSize).compile()
+	reader := bytes.NewReader(payload)
+
+	c := NewClient(hostname, port, pw)
+	c.connection = &replayConn{reader}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		reader.Reset(payload)
+
+		if _, err := c.readPacket(); nil != err {
+			b.Fatal("Expected no error during read", err)
+		}
+	}
+}
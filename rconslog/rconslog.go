@@ -0,0 +1,60 @@
+// Package rconslog adapts a *slog.Logger to the rcon package's Logger,
+// keeping the rcon package itself free of a dependency on log/slog.
+package rconslog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/cpf/rcon"
+)
+
+type logger struct {
+	logger *slog.Logger
+}
+
+// WithSlog returns an rcon.Logger emitting each command to l, with the
+// attributes addr, command and rtt, plus wire for commands sent altered,
+// name for named clients, err for failed commands, dry_run for commands
+// not sent and likely_truncated for responses likely truncated. Commands
+// are logged at the info level, likely truncated responses at the warn
+// level and failed commands at the error level.
+//
+//	client.Logger = rconslog.WithSlog(slog.Default())
+func WithSlog(l *slog.Logger) rcon.Logger {
+	return logger{l}
+}
+
+// Log emits the entry to the slog logger.
+func (this logger) Log(entry rcon.LogEntry) {
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String("addr", entry.Addr),
+		slog.String("command", entry.Command),
+		slog.Duration("rtt", entry.RTT),
+	}
+
+	if entry.Wire != entry.Command {
+		attrs = append(attrs, slog.String("wire", entry.Wire))
+	}
+
+	if "" != entry.Name {
+		attrs = append(attrs, slog.String("name", entry.Name))
+	}
+
+	if entry.DryRun {
+		attrs = append(attrs, slog.Bool("dry_run", true))
+	}
+
+	if entry.LikelyTruncated {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.Bool("likely_truncated", true))
+	}
+
+	if nil != entry.Err {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("err", entry.Err))
+	}
+
+	this.logger.LogAttrs(context.Background(), level, "rcon command", attrs...)
+}
@@ -0,0 +1,28 @@
+package rconslog
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cpf/rcon"
+)
+
+func TestWithSlog(t *testing.T) {
+	var buffer bytes.Buffer
+
+	logger := WithSlog(slog.New(slog.NewTextHandler(&buffer, nil)))
+	logger.Log(rcon.LogEntry{Name: "eu-1", Addr: "localhost:27015", Command: "status", RTT: time.Millisecond, Err: errors.New("failed")})
+
+	line := buffer.String()
+
+	for _, attr := range []string{"level=ERROR", "addr=localhost:27015", "command=status", "rtt=1ms", "err=failed", "name=eu-1"} {
+		if !strings.Contains(line, attr) {
+			t.Log("Expected log line to contain", attr, line)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package rcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Directions of the traffic in a recording.
+const (
+	recordSent     byte = '>'
+	recordReceived byte = '<'
+)
+
+// Record errors.
+var (
+	ErrInvalidRecording = errors.New("Recording is malformed.")
+)
+
+// recorder writes the traffic of a client's connections to a recording.
+// Each chunk of traffic is recorded as its direction, its length as a
+// little endian int32, then the bytes themselves.
+type recorder struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+func (this *recorder) record(direction byte, data []byte) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	var prefix [5]byte
+
+	prefix[0] = direction
+	binary.LittleEndian.PutUint32(prefix[1:], uint32(len(data)))
+
+	this.w.Write(prefix[:])
+	this.w.Write(data)
+}
+
+// recordingConn tees the traffic of a connection to a recorder.
+type recordingConn struct {
+	net.Conn
+	recorder *recorder
+}
+
+func (this *recordingConn) Read(b []byte) (n int, err error) {
+	if n, err = this.Conn.Read(b); n > 0 {
+		this.recorder.record(recordReceived, b[:n])
+	}
+
+	return
+}
+
+// Write records the payload, redacting the body of SERVERDATA_AUTH
+// packets so the password doesn't end up in the recording.
+func (this *recordingConn) Write(b []byte) (int, error) {
+	recorded := b
+
+	if len(b) >= 12 && auth == int32(binary.LittleEndian.Uint32(b[8:])) {
+		recorded = append([]byte(nil), b...)
+
+		for i := 12; i < len(recorded) && 0 != recorded[i]; i++ {
+			recorded[i] = '*'
+		}
+	}
+
+	this.recorder.record(recordSent, recorded)
+
+	return this.Conn.Write(b)
+}
+
+// writeRedacted writes the secret, such as a password sent as text,
+// recording it with each byte but line breaks redacted.
+func (this *recordingConn) writeRedacted(b []byte) (int, error) {
+	recorded := bytes.Map(func(r rune) rune {
+		if '\n' == r || '\r' == r {
+			return r
+		}
+
+		return '*'
+	}, b)
+
+	this.recorder.record(recordSent, recorded)
+
+	return this.Conn.Write(b)
+}
+
+// writeSecret writes the secret to the client's connection, redacting it
+// from the recording, if the connection's recorded.
+func (this *Client) writeSecret(b []byte) (int, error) {
+	conn := this.connection
+
+	for {
+		switch wrapped := conn.(type) {
+		case *peekConn:
+			conn = wrapped.Conn
+		case *recordingConn:
+			return wrapped.writeRedacted(b)
+		default:
+			return this.connection.Write(b)
+		}
+	}
+}
+
+// RecordTo records all bytes sent and received over the client's
+// connection, and any it reconnects with, to w for replaying with
+// ReplayClient. Passwords sent are redacted.
+func (this *Client) RecordTo(w io.Writer) {
+	this.recorder = &recorder{w: w}
+
+	if nil != this.connection {
+		this.connection = &recordingConn{this.connection, this.recorder}
+	}
+}
+
+// ReplayClient creates an authorized Client replaying a session recorded
+// with RecordTo, for reproducing a session's responses without a server.
+// Commands must be executed in the order they were recorded: the client
+// sends each with the challenge recorded for it, discarding its bytes, and
+// reads the responses recorded. Reads fail with io.EOF once the recording
+// is exhausted.
+func ReplayClient(r io.Reader) (client *Client, err error) {
+	var received bytes.Buffer
+	var challenges []int32
+	var prefix [5]byte
+
+	for {
+		if _, err = io.ReadFull(r, prefix[:]); io.EOF == err {
+			break
+		} else if nil != err {
+			return nil, ErrInvalidRecording
+		}
+
+		data := make([]byte, binary.LittleEndian.Uint32(prefix[1:]))
+
+		if _, err = io.ReadFull(r, data); nil != err {
+			return nil, ErrInvalidRecording
+		}
+
+		switch prefix[0] {
+		case recordReceived:
+			received.Write(data)
+		case recordSent:
+			if len(data) < 12 {
+				return nil, ErrInvalidRecording
+			}
+
+			challenges = append(challenges, int32(binary.LittleEndian.Uint32(data[4:])))
+		default:
+			return nil, ErrInvalidRecording
+		}
+	}
+
+	client = NewClient("", 0, "")
+	client.connection = &replayConn{Reader: bytes.NewReader(received.Bytes())}
+	client.authorized = true
+	client.ChallengeFunc = func() (challenge int32) {
+		if 0 != len(challenges) {
+			challenge, challenges = challenges[0], challenges[1:]
+		}
+
+		return
+	}
+
+	return client, nil
+}
+
+// replayConn is a connection reading recorded bytes and discarding writes.
+type replayConn struct {
+	*bytes.Reader
+}
+
+func (this *replayConn) Write(b []byte) (int, error)        { return len(b), nil }
+func (this *replayConn) Close() error                       { return nil }
+func (this *replayConn) LocalAddr() net.Addr                { return nil }
+func (this *replayConn) RemoteAddr() net.Addr               { return nil }
+func (this *replayConn) SetDeadline(t time.Time) error      { return nil }
+func (this *replayConn) SetReadDeadline(t time.Time) error  { return nil }
+func (this *replayConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,85 @@
+package rcon
+
+import (
+	"time"
+)
+
+// AuthRenewErrors returns the channel receiving the errors with which the
+// renewals of AuthRenewInterval fail. It holds the latest failure only
+// while nobody's receiving; older ones are dropped rather than blocking
+// the renewals.
+func (this *Client) AuthRenewErrors() <-chan error {
+	this.renewMutex.Lock()
+	defer this.renewMutex.Unlock()
+
+	return this.renewErrors()
+}
+
+// renewErrors returns the channel of renewal errors, creating it if need
+// be. The renewal mutex must be held.
+func (this *Client) renewErrors() chan error {
+	if nil == this.renewErrs {
+		this.renewErrs = make(chan error, 1)
+	}
+
+	return this.renewErrs
+}
+
+// startRenew starts renewing the client's authorization every
+// AuthRenewInterval, unless it already is or has no interval.
+func (this *Client) startRenew() {
+	if this.AuthRenewInterval <= 0 {
+		return
+	}
+
+	this.renewMutex.Lock()
+	defer this.renewMutex.Unlock()
+
+	if nil != this.renewStop {
+		return
+	}
+
+	this.renewStop = make(chan struct{})
+
+	go this.renew(this.renewStop, this.AuthRenewInterval, this.renewErrors())
+}
+
+// renew authorizes the client again with its password every interval
+// until stopped, reporting failures on errs. Failed renewals are retried
+// on the next interval.
+func (this *Client) renew(stop chan struct{}, interval time.Duration, errs chan error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := this.Authorize(); nil != err {
+			// Replace an unreceived failure with the latest.
+			select {
+			case <-errs:
+			default:
+			}
+
+			select {
+			case errs <- err:
+			default:
+			}
+		}
+	}
+}
+
+// stopRenew stops renewing the client's authorization, if it is.
+func (this *Client) stopRenew() {
+	this.renewMutex.Lock()
+	defer this.renewMutex.Unlock()
+
+	if nil != this.renewStop {
+		close(this.renewStop)
+		this.renewStop = nil
+	}
+}
@@ -0,0 +1,70 @@
+package rcon
+
+import (
+	"errors"
+	"time"
+)
+
+// SentinelSupport is whether a server supports the empty
+// SERVERDATA_RESPONSE_VALUE sent after a command to mark the end of its
+// response, as ExecuteMultiPacket and the commands built on it do.
+type SentinelSupport int32
+
+const (
+	SentinelUnknown     SentinelSupport = iota // Not yet detected.
+	SentinelSupported                          // Mirrored by the server.
+	SentinelUnsupported                        // Breaking the server's response.
+)
+
+// String returns the support's name.
+func (this SentinelSupport) String() string {
+	switch this {
+	case SentinelSupported:
+		return "supported"
+	case SentinelUnsupported:
+		return "unsupported"
+	default:
+		return "unknown"
+	}
+}
+
+// SentinelSupport returns whether the server supports the empty packet
+// marking the end of multi-packet responses, as detected by the first
+// multi-packet command executed since Connect. Servers not supporting it
+// close the connection, fail to respond or respond with a packet of
+// another challenge when sent one; the command detecting it fails, but
+// subsequent multi-packet commands assemble their responses by waiting
+// briefly for further fragments instead, after each fragment close to
+// Valve's limit per packet.
+func (this *Client) SentinelSupport() SentinelSupport {
+	return SentinelSupport(this.sentinel.Load())
+}
+
+// detectSentinel records the server as not supporting the sentinel should
+// its support be unknown and the error reading the response's index'th
+// packet, after sending one, suggest it doesn't.
+func (this *Client) detectSentinel(index int, err error) {
+	if SentinelUnknown != this.SentinelSupport() {
+		return
+	}
+
+	timedOut := errors.Is(err, ErrTimeout) || errors.Is(err, ErrIncompleteResponse)
+	unmirrored := errors.Is(err, ErrInvalidChallenge)
+
+	if 0 < index && (timedOut || unmirrored) || !timedOut && isConnectionError(err) {
+		this.sentinel.Store(int32(SentinelUnsupported))
+	}
+}
+
+// boundQuiet bounds reading the next fragment of a response assembled
+// without a sentinel by drainTimeout, unless the command's deadline is
+// sooner.
+func (this *Client) boundQuiet() {
+	deadline := time.Now().Add(drainTimeout)
+
+	if !this.deadline.IsZero() && this.deadline.Before(deadline) {
+		deadline = this.deadline
+	}
+
+	this.connection.SetReadDeadline(deadline)
+}
@@ -0,0 +1,3577 @@
+package rcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// mockServer is a single connection RCON server for testing the client
+// against scripted server behaviour.
+type mockServer struct {
+	listener net.Listener
+	done     chan struct{}
+}
+
+// newMockServer starts a server, handing the first connection it accepts
+// to serve, which scripts the server's side of the conversation.
+func newMockServer(t *testing.T, serve func(conn mockConn)) *mockServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal("Expected no error during listen", err)
+	}
+
+	server := &mockServer{listener, make(chan struct{})}
+
+	go func() {
+		defer close(server.done)
+
+		conn, err := listener.Accept()
+		if nil != err {
+			return
+		}
+		defer conn.Close()
+
+		serve(mockConn{&Client{connection: conn, PaddingSize: packetPaddingSize}})
+	}()
+
+	return server
+}
+
+// newMockListener starts a server handing every connection it accepts to
+// serve on a goroutine of its own, numbered from 0 in the order accepted,
+// for testing reconnections and clients of several connections. Closing
+// the listener stops it.
+func newMockListener(t *testing.T, serve func(n int, conn mockConn)) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal("Expected no error during listen", err)
+	}
+
+	go func() {
+		for n := 0; ; n++ {
+			conn, err := listener.Accept()
+			if nil != err {
+				return
+			}
+
+			go func(n int) {
+				defer conn.Close()
+
+				serve(n, mockConn{&Client{connection: conn, PaddingSize: packetPaddingSize}})
+			}(n)
+		}
+	}()
+
+	return listener
+}
+
+// client returns a client connected to the server.
+func (this *mockServer) client(t *testing.T, password string) *Client {
+	addr := this.listener.Addr().(*net.TCPAddr)
+
+	c := NewClient(addr.IP.String(), addr.Port, password)
+	if err := c.Connect(); nil != err {
+		t.Fatal("Expected no error during connect", err)
+	}
+
+	return c
+}
+
+// close stops the server, waiting for its connection to be served.
+func (this *mockServer) close() {
+	this.listener.Close()
+	<-this.done
+}
+
+// mockConn is the server's side of a connection, reusing the client's
+// packet framing.
+type mockConn struct {
+	*Client
+}
+
+// respond writes a packet with the challenge, type and body to the client.
+func (this mockConn) respond(challenge, typ int32, body string) error {
+	return this.writePacket(newPacket(challenge, typ, body, packetPaddingSize))
+}
+
+// acceptAuth serves a Source style authorization: an empty
+// SERVERDATA_RESPONSE_VALUE followed by the SERVERDATA_AUTH_RESPONSE.
+func (this mockConn) acceptAuth(password string) (ok bool) {
+	request, err := this.readPacket()
+	if nil != err || request.Header.headerType != auth {
+		return false
+	}
+
+	challenge := request.Header.challenge
+	if request.Body != password {
+		challenge = -1
+	}
+
+	this.respond(request.Header.challenge, responseValue, "")
+	this.respond(challenge, authResponse, "")
+
+	return request.Body == password
+}
+
+func TestMockAuthorizeOrderings(t *testing.T) {
+	orderings := map[string][]int32{
+		"preamble before":   {responseValue, authResponse},
+		"no preamble":       {authResponse},
+		"preamble after":    {authResponse, responseValue},
+		"repeated preamble": {responseValue, responseValue, authResponse},
+	}
+
+	for name, ordering := range orderings {
+		server := newMockServer(t, func(conn mockConn) {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			for _, typ := range ordering {
+				conn.respond(request.Header.challenge, typ, "")
+			}
+
+			if request, err = conn.readPacket(); nil == err {
+				conn.respond(request.Header.challenge, responseValue, "hostname: mock")
+			}
+		})
+
+		c := server.client(t, pw)
+
+		if _, err := c.Authorize(); nil != err {
+			t.Log(name, "expected no error during authorize", err)
+			t.Fail()
+		} else if response, err := c.Execute("status"); nil != err || response.Body != "hostname: mock" {
+			t.Log(name, "unexpected response after authorize", response, err)
+			t.Fail()
+		}
+
+		c.Disconnect()
+		server.close()
+	}
+}
+
+func TestMockSkipAuthPreambleDiscard(t *testing.T) {
+	orderings := map[string][]int32{
+		"no preamble":     {authResponse},
+		"preamble before": {responseValue, authResponse},
+	}
+
+	for name, ordering := range orderings {
+		server := newMockServer(t, func(conn mockConn) {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			for _, typ := range ordering {
+				conn.respond(request.Header.challenge, typ, "")
+			}
+
+			if request, err = conn.readPacket(); nil == err {
+				conn.respond(request.Header.challenge, responseValue, "hostname: mock")
+			}
+		})
+
+		c := server.client(t, pw)
+		c.SkipAuthPreambleDiscard = true
+
+		// The preamble's read as the response, rather than discarded.
+		_, err := c.Authorize()
+
+		if 1 < len(ordering) {
+			if !errors.Is(err, ErrFailedAuthorization) {
+				t.Log(name, "expected ErrFailedAuthorization reading the preamble, got", err)
+				t.Fail()
+			}
+		} else if nil != err {
+			t.Log(name, "expected no error during authorize", err)
+			t.Fail()
+		} else if response, err := c.Execute("status"); nil != err || response.Body != "hostname: mock" {
+			t.Log(name, "unexpected response after authorize", response, err)
+			t.Fail()
+		}
+
+		c.Disconnect()
+		server.close()
+	}
+}
+
+func TestMockAuthorize(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		conn.acceptAuth(pw)
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error during authorize", err)
+		t.Fail()
+	}
+}
+
+func TestMockWrongPassword(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		if !conn.acceptAuth(pw) {
+			conn.acceptAuth(pw)
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, "wrong")
+	defer c.Disconnect()
+
+	_, err := c.Authorize()
+	if nil == err {
+		t.Log("Expected error during authorize")
+		t.FailNow()
+	}
+
+	if prefix := "rcon " + c.ActiveAddr() + ": "; !strings.HasPrefix(err.Error(), prefix) {
+		t.Log("Expected error to be prefixed with", prefix, err)
+		t.Fail()
+	}
+
+	c.Name = "eu-1"
+
+	if _, err = c.Authorize(); nil == err {
+		t.Log("Expected error during authorize")
+		t.FailNow()
+	}
+
+	if prefix := "rcon eu-1 (" + c.ActiveAddr() + "): "; !strings.HasPrefix(err.Error(), prefix) {
+		t.Log("Expected error to be prefixed with", prefix, err)
+		t.Fail()
+	}
+}
+
+func TestMockAuthorizeReader(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		if conn.acceptAuth(pw) {
+			conn.acceptAuth(pw)
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, "")
+	defer c.Disconnect()
+
+	if _, err := c.AuthorizeReader(strings.NewReader(pw + "\n")); nil != err {
+		t.Log("Expected no error during authorize", err)
+		t.Fail()
+	}
+
+	if _, err := c.AuthorizeReader(strings.NewReader("wrong")); nil == err {
+		t.Log("Expected error during authorize with the wrong password")
+		t.Fail()
+	}
+}
+
+func TestMockAuthorizeReaderTooLong(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		conn.readPacket()
+	})
+	defer server.close()
+
+	c := server.client(t, "")
+	defer c.Disconnect()
+
+	long := strings.Repeat("x", maxPasswordLength+1)
+
+	for _, password := range []string{long, long + "\n"} {
+		if _, err := c.AuthorizeReader(strings.NewReader(password)); !errors.Is(err, ErrPasswordTooLong) {
+			t.Log("Expected ErrPasswordTooLong, got", err)
+			t.Fail()
+		}
+	}
+}
+
+func TestMockAuthorizeReaderReconnect(t *testing.T) {
+	authorized := make(chan bool, 2)
+
+	// Authorizing with the client's own, empty, password fails.
+	listener := newMockListener(t, func(n int, server mockConn) {
+		ok := server.acceptAuth(pw)
+		authorized <- ok
+
+		if ok {
+			server.readPacket()
+		}
+	})
+	defer listener.Close()
+
+	c := NewClient("", 0, "")
+	c.Addresses = []string{listener.Addr().String()}
+	defer c.Disconnect()
+
+	if err := c.Connect(); nil != err {
+		t.Fatal("Expected no error during connect", err)
+	} else if _, err = c.AuthorizeReader(strings.NewReader(pw)); nil != err {
+		t.Fatal("Expected no error during authorize", err)
+	}
+
+	<-authorized
+
+	// The password wasn't kept, so the reconnection's left unauthorized
+	// rather than authorized with the client's password.
+	if err := c.reconnect(); nil != err {
+		t.Fatal("Expected no error reconnecting", err)
+	} else if c.Authorized() {
+		t.Log("Expected the client unauthorized after reconnecting")
+		t.Fail()
+	}
+
+	if _, err := c.Execute("status"); !errors.Is(err, ErrUnauthorizedRequest) {
+		t.Log("Expected ErrUnauthorizedRequest after reconnecting, got", err)
+		t.Fail()
+	}
+
+	if _, err := c.AuthorizeReader(strings.NewReader(pw)); nil != err {
+		t.Log("Expected no error authorizing again", err)
+		t.Fail()
+	} else if !<-authorized {
+		t.Log("Expected the server to accept the password read")
+		t.Fail()
+	}
+}
+
+func TestMockAuthResponseType(t *testing.T) {
+	const custom int32 = 5
+
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			conn.respond(request.Header.challenge, custom, "")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	if _, err := c.Authorize(); !errors.Is(err, ErrFailedAuthorization) {
+		t.Log("Expected ErrFailedAuthorization with the default type, got", err)
+		t.Fail()
+	}
+
+	c.AuthResponseType = custom
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error during authorize with the custom type", err)
+		t.Fail()
+	}
+}
+
+func TestMockAuthResponseTypeValue(t *testing.T) {
+	for _, preamble := range []bool{false, true} {
+		server := newMockServer(t, func(conn mockConn) {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			if preamble {
+				conn.respond(request.Header.challenge, responseValue, "")
+			}
+
+			conn.respond(request.Header.challenge, responseValue, "")
+
+			if request, err = conn.readPacket(); nil == err {
+				conn.respond(request.Header.challenge, responseValue, "hostname: mock")
+			}
+		})
+
+		c := server.client(t, pw)
+		c.AuthResponseType = responseValue
+		c.Timeout = 2 * time.Second
+
+		if _, err := c.Authorize(); nil != err {
+			t.Log("Expected no error authorizing with SERVERDATA_RESPONSE_VALUE, preamble", preamble, err)
+			t.Fail()
+		} else if response, err := c.Execute("status"); nil != err || "hostname: mock" != response.Body {
+			t.Log("Unexpected response after authorizing, preamble", preamble, response, err)
+			t.Fail()
+		}
+
+		c.Disconnect()
+		server.close()
+	}
+}
+
+func TestMockAuthNonEmptyPreamble(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		request, err := conn.readPacket()
+		if nil != err {
+			return
+		}
+
+		conn.respond(request.Header.challenge, responseValue, "Message of the day")
+		conn.respond(request.Header.challenge, authResponse, "")
+		conn.readPacket()
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	// The non-empty packet isn't discarded as the preamble, so it's taken
+	// for the authorization's response.
+	if _, err := c.Authorize(); !errors.Is(err, ErrFailedAuthorization) {
+		t.Log("Expected ErrFailedAuthorization from a non-empty SERVERDATA_RESPONSE_VALUE, got", err)
+		t.Fail()
+	}
+}
+
+func TestMockExecuteAs(t *testing.T) {
+	const custom int32 = 7
+
+	server := newMockServer(t, func(conn mockConn) {
+		if !conn.acceptAuth(pw) {
+			return
+		}
+
+		request, err := conn.readPacket()
+		if nil != err {
+			return
+		}
+
+		body := "unexpected type"
+		if request.Header.headerType == custom {
+			body = "custom " + request.Body
+		}
+
+		conn.respond(request.Header.challenge, responseValue, body)
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	if _, err := c.ExecuteAs(custom, "status"); !errors.Is(err, ErrUnauthorizedRequest) {
+		t.Log("Expected ErrUnauthorizedRequest before authorize, got", err)
+		t.Fail()
+	}
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error during authorize", err)
+		t.FailNow()
+	}
+
+	response, err := c.ExecuteAs(custom, "status")
+	if nil != err {
+		t.Log("Expected no error during execute", err)
+		t.FailNow()
+	}
+
+	if response.Body != "custom status" {
+		t.Log("Unexpected response body", response.Body)
+		t.Fail()
+	}
+}
+
+func TestMockExecuteBytes(t *testing.T) {
+	// Invalid UTF-8, with a null inside.
+	body := []byte{0xff, 0xfe, 0x00, 0xc3, 0x28, 0x80, 'z'}
+	received := make(chan []byte, 1)
+
+	server := newMockServer(t, func(conn mockConn) {
+		request, err := conn.readPacket()
+		if nil != err {
+			return
+		}
+
+		received <- request.BodyBytes()
+		conn.respond(request.Header.challenge, responseValue, request.Body)
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	response, err := c.ExecuteBytes(exec, body)
+	if nil != err {
+		t.Fatal("Expected no error during execute", err)
+	}
+
+	if sent := <-received; !bytes.Equal(body, sent) {
+		t.Log("Expected the body sent unaltered", sent)
+		t.Fail()
+	}
+
+	if !bytes.Equal(body, response.BodyBytes()) {
+		t.Log("Expected the body to round trip", response.BodyBytes())
+		t.Fail()
+	}
+}
+
+func TestMockReadPacket(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		conn.respond(0, responseValue, "L 10/15/2026 - 12:00:00: server pushed")
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	packet, err := c.ReadPacket()
+	if nil != err {
+		t.Log("Expected no error during read", err)
+		t.FailNow()
+	}
+
+	if packet.Body != "L 10/15/2026 - 12:00:00: server pushed" {
+		t.Log("Unexpected packet body", packet.Body)
+		t.Fail()
+	}
+}
+
+func TestMockUnterminatedBody(t *testing.T) {
+	for _, validation := range []PaddingValidation{PaddingStrict, PaddingLenient, PaddingIgnore} {
+		server := newMockServer(t, func(conn mockConn) {
+			if !conn.acceptAuth(pw) {
+				return
+			}
+
+			if request, err := conn.readPacket(); nil == err {
+				conn.writePacket(newPacket(request.Header.challenge, responseValue, "unterminated", 0))
+			}
+		})
+
+		c := server.client(t, pw)
+		c.PaddingValidation = validation
+
+		if _, err := c.Authorize(); nil != err {
+			t.Log("Expected no error during authorize", err)
+			t.Fail()
+		}
+
+		response, err := c.Execute("status")
+
+		if PaddingStrict == validation && !errors.Is(err, ErrInvalidTermination) {
+			t.Log("Expected ErrInvalidTermination, got", err)
+			t.Fail()
+		} else if PaddingStrict != validation && (nil != err || response.Body != "unterminated") {
+			t.Log("Expected the body accepted with validation", validation, response, err)
+			t.Fail()
+		}
+
+		c.Disconnect()
+		server.close()
+	}
+}
+
+func TestProbe(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		if request, err := conn.readPacket(); nil == err {
+			conn.respond(request.Header.challenge, responseValue, "")
+		}
+	})
+	defer server.close()
+
+	addr := server.listener.Addr().(*net.TCPAddr)
+
+	if err := Probe(addr.IP.String(), addr.Port, time.Second); nil != err {
+		t.Log("Expected no error during probe", err)
+		t.Fail()
+	}
+}
+
+func TestProbeRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal("Expected no error during listen", err)
+	}
+
+	addr := listener.Addr().(*net.TCPAddr)
+	listener.Close()
+
+	if err = Probe(addr.IP.String(), addr.Port, time.Second); ErrConnectionRefused != err {
+		t.Log("Expected ErrConnectionRefused, got", err)
+		t.Fail()
+	}
+}
+
+func TestMockTimeout(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		if conn.acceptAuth(pw) {
+			conn.readPacket()
+			conn.readPacket()
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error during authorize", err)
+		t.FailNow()
+	}
+
+	c.Timeout = 50 * time.Millisecond
+
+	_, err := c.Execute("status")
+
+	var ne net.Error
+	if !errors.Is(err, ErrTimeout) || !errors.As(err, &ne) || !ne.Timeout() {
+		t.Log("Expected ErrTimeout, got", err)
+		t.Fail()
+	}
+}
+
+func TestMockRecordReplay(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		if !conn.acceptAuth(pw) {
+			return
+		}
+
+		if request, err := conn.readPacket(); nil == err {
+			conn.respond(request.Header.challenge, responseValue, "hostname: recorded")
+		}
+	})
+	defer server.close()
+
+	var recording bytes.Buffer
+
+	c := server.client(t, pw)
+	c.RecordTo(&recording)
+	defer c.Disconnect()
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error during authorize", err)
+		t.FailNow()
+	} else if _, err = c.Execute("status"); nil != err {
+		t.Log("Expected no error during execute", err)
+		t.FailNow()
+	}
+
+	if bytes.Contains(recording.Bytes(), []byte(pw)) {
+		t.Log("Expected password to be redacted from recording")
+		t.Fail()
+	}
+
+	replay, err := ReplayClient(&recording)
+	if nil != err {
+		t.Log("Expected no error during replay", err)
+		t.FailNow()
+	}
+
+	if _, err = replay.Authorize(); nil != err {
+		t.Log("Expected no error during replayed authorize", err)
+		t.FailNow()
+	}
+
+	response, err := replay.Execute("status")
+	if nil != err || response.Body != "hostname: recorded" {
+		t.Log("Unexpected replayed response", response, err)
+		t.Fail()
+	}
+}
+
+func TestMockKick(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		if !conn.acceptAuth(pw) {
+			return
+		}
+
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			body := "Kicked by Console : \"Cheating\""
+			if request.Body != `kickid "2" "Cheating"` {
+				body = "userid \"3\" not found"
+			}
+
+			conn.respond(request.Header.challenge, responseValue, body)
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error during authorize", err)
+		t.FailNow()
+	}
+
+	if err := c.Kick(2, "Cheating"); nil != err {
+		t.Log("Expected no error during kick", err)
+		t.Fail()
+	}
+
+	if err := c.Kick(3, "Cheating"); ErrPlayerNotFound != err {
+		t.Log("Expected ErrPlayerNotFound, got", err)
+		t.Fail()
+	}
+}
+
+// The runs of TestMockMetrics, numbering the expvar prefixes it publishes.
+var expvarRuns atomic.Int32
+
+func TestMockMetrics(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		conn.acceptAuth(pw)
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error during authorize", err)
+		t.FailNow()
+	}
+
+	// expvar panics on republishing a name, so each run needs a prefix of
+	// its own, e.g. with -count.
+	prefix := fmt.Sprint(t.Name(), "_", expvarRuns.Add(1))
+	c.PublishExpvar(prefix)
+
+	if value := expvar.Get(prefix + ".commands").String(); value != "1" {
+		t.Log("Expected 1 command published, got", value)
+		t.Fail()
+	}
+
+	if metrics := c.Metrics(); metrics.BytesSent != 26 || metrics.BytesReceived != 28 || metrics.Errors != 0 {
+		t.Log("Unexpected metrics", metrics)
+		t.Fail()
+	}
+}
+
+func TestMockHeaderOnlyResponse(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		if !conn.acceptAuth(pw) {
+			return
+		}
+
+		if request, err := conn.readPacket(); nil == err {
+			conn.writePacket(newPacket(request.Header.challenge, responseValue, "", 0))
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error during authorize", err)
+		t.FailNow()
+	}
+
+	response, err := c.Execute("status")
+	if nil != err || response.Body != "" || response.Header.Size() != packetHeaderSize {
+		t.Log("Expected empty response, got", response, err)
+		t.Fail()
+	}
+}
+
+func TestMockPaddingBytes(t *testing.T) {
+	padding := make(chan []byte, 1)
+
+	server := newMockServer(t, func(conn mockConn) {
+		header, err := conn.readHeader()
+		if nil != err {
+			return
+		}
+
+		body := make([]byte, header.size-packetHeaderSize)
+		io.ReadFull(conn.connection, body)
+		padding <- body[len(body)-2:]
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.PaddingBytes = [2]byte{0xAA, 0xBB}
+	c.Timeout = 50 * time.Millisecond
+	c.Authorize()
+
+	if received := <-padding; !bytes.Equal(received, []byte{0xAA, 0xBB}) {
+		t.Log("Unexpected padding", received)
+		t.Fail()
+	}
+}
+
+func TestMockExecuteAll(t *testing.T) {
+	// The first connection drops after the first command, the second
+	// serves the rest.
+	listener := newMockListener(t, func(n int, server mockConn) {
+		if !server.acceptAuth(pw) {
+			return
+		}
+
+		for j := 0; n > 0 || j < 1; j++ {
+			request, err := server.readPacket()
+			if nil != err {
+				return
+			}
+
+			server.respond(request.Header.challenge, responseValue, request.Body)
+		}
+	})
+	defer listener.Close()
+
+	c := NewClient("", 0, pw)
+	c.Addresses = []string{listener.Addr().String()}
+	defer c.Disconnect()
+
+	if err := c.Connect(); nil != err {
+		t.Fatal("Expected no error during connect", err)
+	} else if _, err = c.Authorize(); nil != err {
+		t.Fatal("Expected no error during authorize", err)
+	}
+
+	results := c.ExecuteAll([]string{"one", "two", "three"})
+
+	if nil != results[0].Err || results[0].Packet.Body != "one" {
+		t.Log("Expected first command to succeed", results[0])
+		t.Fail()
+	}
+
+	if nil == results[1].Err {
+		t.Log("Expected second command to fail on the dropped connection")
+		t.Fail()
+	}
+
+	if nil != results[2].Err || results[2].Packet.Body != "three" {
+		t.Log("Expected third command to succeed after reconnecting", results[2])
+		t.Fail()
+	}
+}
+
+func TestMockFailover(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal("Expected no error during listen", err)
+	}
+
+	dead := closed.Addr().String()
+	closed.Close()
+
+	listener := newMockListener(t, func(n int, server mockConn) {
+		if !server.acceptAuth(pw) {
+			return
+		}
+
+		for {
+			request, err := server.readPacket()
+			if nil != err {
+				return
+			}
+
+			server.respond(request.Header.challenge, responseValue, request.Body)
+		}
+	})
+	defer listener.Close()
+
+	live := listener.Addr().String()
+
+	c := NewClient("", 0, pw)
+	c.Addresses = []string{dead, live}
+	c.DialTimeout = time.Second
+	defer c.Disconnect()
+
+	if err = c.Connect(); nil != err {
+		t.Fatal("Expected no error failing over to the live address", err)
+	} else if addr := c.ActiveAddr(); live != addr {
+		t.Log("Expected the live address active, got", addr)
+		t.Fail()
+	}
+
+	if _, err = c.Authorize(); nil != err {
+		t.Fatal("Expected no error during authorize", err)
+	} else if response, err := c.Execute("echo"); nil != err || "echo" != response.Body {
+		t.Log("Unexpected response from the live address", response, err)
+		t.Fail()
+	}
+
+	// Reconnecting starts over from the first address, failing over again.
+	if err = c.reconnect(); nil != err {
+		t.Fatal("Expected no error reconnecting", err)
+	} else if addr := c.ActiveAddr(); live != addr {
+		t.Log("Expected the live address active after reconnecting, got", addr)
+		t.Fail()
+	}
+
+	c.Addresses = []string{dead}
+
+	if err = c.reconnect(); nil == err {
+		t.Log("Expected an error with no live address")
+		t.Fail()
+	} else if addr := c.ActiveAddr(); "" != addr {
+		t.Log("Expected no active address, got", addr)
+		t.Fail()
+	}
+}
+
+func TestMockTextAuth(t *testing.T) {
+	for password, expected := range map[string]error{pw: nil, "wrong": ErrFailedAuthorization} {
+		server := newMockServer(t, func(conn mockConn) {
+			conn.connection.Write([]byte("Please enter password: "))
+
+			line := make([]byte, 64)
+			n, _ := conn.connection.Read(line)
+
+			if string(line[:n]) == pw+"\n" {
+				conn.connection.Write([]byte("Authenticated.\n"))
+			} else {
+				conn.connection.Write([]byte("Access denied.\n"))
+			}
+		})
+
+		c := server.client(t, password)
+		c.AllowTextAuth = true
+
+		if _, err := c.Authorize(); !errors.Is(err, expected) {
+			t.Log("Expected", expected, "during text authorize, got", err)
+			t.Fail()
+		} else if c.authorized != (nil == expected) {
+			t.Log("Unexpected authorized state", c.authorized)
+			t.Fail()
+		}
+
+		c.Disconnect()
+		server.close()
+	}
+}
+
+func TestMockTextAuthReplies(t *testing.T) {
+	replies := map[string]error{
+		"Login successful.\n":      nil,
+		"Welcome, admin.\n":        nil,
+		"Login unsuccessful.\n":    ErrFailedAuthorization,
+		"You are not logged in.\n": ErrFailedAuthorization,
+		"Not authenticated.\n":     ErrFailedAuthorization,
+	}
+
+	for reply, expected := range replies {
+		server := newMockServer(t, func(conn mockConn) {
+			conn.connection.Write([]byte("Password: "))
+
+			line := make([]byte, 64)
+			conn.connection.Read(line)
+			conn.connection.Write([]byte(reply))
+		})
+
+		c := server.client(t, pw)
+		c.AllowTextAuth = true
+
+		if _, err := c.Authorize(); !errors.Is(err, expected) {
+			t.Log("Expected", expected, "for reply", reply, "got", err)
+			t.Fail()
+		} else if c.authorized != (nil == expected) {
+			t.Log("Unexpected authorized state for reply", reply, c.authorized)
+			t.Fail()
+		}
+
+		c.Disconnect()
+		server.close()
+	}
+}
+
+func TestMockTextAuthBinaryServer(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		// Send the start of the preamble within the prompt window, and
+		// the rest once the password's received.
+		preamble, _ := newPacket(0, responseValue, "", packetPaddingSize).compile()
+		conn.connection.Write(preamble[:4])
+
+		request, err := conn.readPacket()
+		if nil != err {
+			return
+		}
+
+		conn.connection.Write(preamble[4:])
+		conn.respond(request.Header.challenge, authResponse, "")
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.AllowTextAuth = true
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error authorizing to a binary server sending early", err)
+		t.Fail()
+	}
+}
+
+func TestMockTextAuthRecording(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		conn.connection.Write([]byte("Password: "))
+
+		line := make([]byte, 64)
+		conn.connection.Read(line)
+		conn.connection.Write([]byte("Authenticated.\n"))
+	})
+	defer server.close()
+
+	var recording bytes.Buffer
+
+	c := server.client(t, pw)
+	c.RecordTo(&recording)
+	defer c.Disconnect()
+
+	c.AllowTextAuth = true
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error during text authorize", err)
+		t.FailNow()
+	}
+
+	if bytes.Contains(recording.Bytes(), []byte(pw)) {
+		t.Log("Expected the text password to be redacted from the recording")
+		t.Fail()
+	} else if !bytes.Contains(recording.Bytes(), []byte(strings.Repeat("*", len(pw))+"\n")) {
+		t.Log("Expected the redacted password in the recording", recording.String())
+		t.Fail()
+	}
+}
+
+func TestConnectMany(t *testing.T) {
+	listener := newMockListener(t, func(n int, server mockConn) {
+		server.readPacket()
+	})
+	defer listener.Close()
+
+	addr := listener.Addr().(*net.TCPAddr)
+
+	var clients []*Client
+	for i := 0; i < 5; i++ {
+		clients = append(clients, NewClient(addr.IP.String(), addr.Port, pw))
+	}
+
+	for i, err := range ConnectMany(clients, 2) {
+		if nil != err {
+			t.Log("Expected no error connecting client", i, err)
+			t.Fail()
+		}
+
+		clients[i].Disconnect()
+	}
+}
+
+func TestMockAuthResponseBody(t *testing.T) {
+	for _, body := range []string{"", "reflected body"} {
+		for _, password := range []string{pw, "wrong"} {
+			server := newMockServer(t, func(conn mockConn) {
+				request, err := conn.readPacket()
+				if nil != err {
+					return
+				}
+
+				challenge := request.Header.challenge
+				if request.Body != pw {
+					challenge = -1
+				}
+
+				conn.respond(request.Header.challenge, responseValue, "")
+				conn.respond(challenge, authResponse, body)
+			})
+
+			c := server.client(t, password)
+			c.authorized = true
+
+			response, err := c.Authorize()
+
+			if password == pw && (nil != err || !c.authorized || response.Body != body) {
+				t.Logf("Expected authorize with body %q to succeed, got %v %v", body, response, err)
+				t.Fail()
+			} else if password != pw && (!errors.Is(err, ErrInvalidChallenge) || c.authorized) {
+				t.Logf("Expected authorize with body %q to fail, got %v", body, err)
+				t.Fail()
+			}
+
+			c.Disconnect()
+			server.close()
+		}
+	}
+}
+
+func TestMockTailConsole(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		if !conn.acceptAuth(pw) {
+			return
+		}
+
+		if request, err := conn.readPacket(); nil == err && request.Body == "console_stream 1" {
+			conn.respond(request.Header.challenge, responseValue, "")
+			conn.respond(0, responseValue, "first line\nsecond line\n")
+		}
+
+		conn.readPacket()
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error during authorize", err)
+		t.FailNow()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.TailConsoleCommand = "console_stream 1"
+
+	lines, err := c.TailConsole(ctx)
+	if nil != err {
+		t.Log("Expected no error during tail", err)
+		t.FailNow()
+	}
+
+	for _, expected := range []string{"first line", "second line"} {
+		if line := <-lines; line != expected {
+			t.Log("Expected", expected, "got", line)
+			t.Fail()
+		}
+	}
+
+	cancel()
+
+	if _, open := <-lines; open {
+		t.Log("Expected lines to close once cancelled")
+		t.Fail()
+	}
+}
+
+func TestPoolWarmup(t *testing.T) {
+	listener := newMockListener(t, func(n int, server mockConn) {
+		server.acceptAuth(pw)
+		server.readPacket()
+	})
+	defer listener.Close()
+
+	addr := listener.Addr().(*net.TCPAddr)
+
+	pool := NewPool(addr.IP.String(), addr.Port, pw, 3)
+	defer pool.Close()
+
+	if connected, err := pool.Warmup(context.Background(), 3); nil != err || connected != 3 {
+		t.Log("Expected all connections established during warmup", connected, err)
+		t.FailNow()
+	}
+
+	c, err := pool.Get(context.Background())
+	if nil != err {
+		t.Log("Expected no error during get", err)
+		t.FailNow()
+	}
+
+	if !c.Authorized() {
+		t.Log("Expected a warmed up client to be authorized")
+		t.Fail()
+	}
+
+	pool.Put(c)
+
+	wrong := NewPool(addr.IP.String(), addr.Port, "wrong", 2)
+	defer wrong.Close()
+
+	if connected, err := wrong.Warmup(context.Background(), 1); !errors.Is(err, ErrPoolWarmup) || connected != 0 {
+		t.Log("Expected ErrPoolWarmup with the wrong password, got", connected, err)
+		t.Fail()
+	}
+}
+
+func TestPoolMaxConcurrentDials(t *testing.T) {
+	var active, peak atomic.Int32
+
+	listener := newMockListener(t, func(n int, server mockConn) {
+		// Count the connection as dialing until it's authorized.
+		if dialing := active.Add(1); dialing > peak.Load() {
+			peak.Store(dialing)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		active.Add(-1)
+
+		server.acceptAuth(pw)
+		server.readPacket()
+	})
+	defer listener.Close()
+
+	addr := listener.Addr().(*net.TCPAddr)
+
+	pool := NewPool(addr.IP.String(), addr.Port, pw, 3)
+	pool.MaxConcurrentDials = 1
+	defer pool.Close()
+
+	if connected, err := pool.Warmup(context.Background(), 3); nil != err || connected != 3 {
+		t.Log("Expected all connections established during warmup", connected, err)
+		t.FailNow()
+	}
+
+	if 1 != peak.Load() {
+		t.Log("Expected a single dial at once, got", peak.Load())
+		t.Fail()
+	}
+}
+
+func TestMockWaitForCvar(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		if !conn.acceptAuth(pw) {
+			return
+		}
+
+		for i := 0; ; i++ {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			value := "1"
+			if i >= 2 {
+				value = "0"
+			}
+
+			conn.respond(request.Header.challenge, responseValue, "\"mp_restartgame\" = \""+value+"\"\n")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	if _, err := c.Authorize(); nil != err {
+		t.Log("Expected no error during authorize", err)
+		t.FailNow()
+	}
+
+	if err := c.WaitForCvar("mp_restartgame", "0", 5*time.Second); nil != err {
+		t.Log("Expected no error waiting for the cvar", err)
+		t.Fail()
+	}
+
+	err := c.WaitForCvar("mp_restartgame", "5", 0)
+	if !errors.Is(err, ErrCvarWaitTimeout) || !strings.Contains(err.Error(), `"0"`) {
+		t.Log("Expected ErrCvarWaitTimeout with the last value, got", err)
+		t.Fail()
+	}
+}
+
+func BenchmarkExecute(b *testing.B) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+
+		conn := mockConn{&Client{connection: server, PaddingSize: packetPaddingSize}}
+
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			conn.respond(request.Header.challenge, responseValue, "hostname: mock")
+		}
+	}()
+
+	c := NewClient(hostname, port, pw)
+	c.connection = client
+	c.authorized = true
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Execute("status"); nil != err {
+			b.Fatal("Expected no error during execute", err)
+		}
+	}
+}
+
+func TestMockDetectProtocol(t *testing.T) {
+	servers := map[ProtocolKind]func(conn mockConn){
+		ProtocolSource: func(conn mockConn) {
+			conn.acceptAuth(pw)
+		},
+		ProtocolMinecraft: func(conn mockConn) {
+			if request, err := conn.readPacket(); nil == err {
+				conn.respond(request.Header.challenge, authResponse, "")
+				conn.readPacket()
+			}
+		},
+		ProtocolText: func(conn mockConn) {
+			conn.connection.Write([]byte("Password: "))
+			conn.readPacket()
+		},
+	}
+
+	for expected, serve := range servers {
+		server := newMockServer(t, serve)
+		c := server.client(t, pw)
+
+		if kind, err := c.DetectProtocol(); nil != err || kind != expected {
+			t.Log("Expected", expected, "got", kind, err)
+			t.Fail()
+		} else if kind != ProtocolText && !c.authorized {
+			t.Log("Expected detection to authorize the", kind, "client")
+			t.Fail()
+		}
+
+		c.Disconnect()
+		server.close()
+	}
+}
+
+func TestMockMulti(t *testing.T) {
+	responsive := newMockServer(t, func(conn mockConn) {
+		if request, err := conn.readPacket(); nil == err {
+			conn.respond(request.Header.challenge, responseValue, "hostname: mock")
+		}
+	})
+	defer responsive.close()
+
+	silent := newMockServer(t, func(conn mockConn) {
+		conn.readPacket()
+		conn.readPacket()
+	})
+	defer silent.close()
+
+	a, b := responsive.client(t, pw), silent.client(t, pw)
+	defer a.Disconnect()
+	defer b.Disconnect()
+
+	a.Name, a.authorized = "a", true
+	b.Name, b.authorized = "b", true
+
+	results := Multi{Clients: []*Client{a, b}, Timeout: 200 * time.Millisecond}.Execute("status")
+
+	if result := results["a"]; nil != result.Err || "hostname: mock" != result.Packet.Body {
+		t.Log("Unexpected result of the responsive server", result)
+		t.Fail()
+	}
+
+	if result := results["b"]; !errors.Is(result.Err, ErrTimeout) {
+		t.Log("Expected ErrTimeout from the silent server, got", result.Err)
+		t.Fail()
+	}
+}
+
+func TestMockExecuteDetectFragmented(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		request, err := conn.readPacket()
+		if nil != err {
+			return
+		}
+
+		conn.respond(request.Header.challenge, responseValue, strings.Repeat("a", 4096))
+		conn.respond(request.Header.challenge, responseValue, "rest")
+
+		if request, err = conn.readPacket(); nil == err {
+			conn.respond(request.Header.challenge, responseValue, "short")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	if response, fragmented, err := c.ExecuteDetectFragmented("cvarlist"); nil != err || !fragmented || 4096 != len(response.Body) {
+		t.Log("Expected a fragmented response", fragmented, err)
+		t.Fail()
+	}
+
+	if response, fragmented, err := c.ExecuteDetectFragmented("status"); nil != err || fragmented || "short" != response.Body {
+		t.Log("Expected an unfragmented response after the discarded fragments", response, fragmented, err)
+		t.Fail()
+	}
+}
+
+func TestMockOversizedPacket(t *testing.T) {
+	for _, limit := range []int{0, 1024} {
+		server := newMockServer(t, func(conn mockConn) {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			// Declare a body of nearly 2 GiB, but send only a few bytes.
+			payload := binary.LittleEndian.AppendUint32(nil, 1<<31-1)
+			payload = binary.LittleEndian.AppendUint32(payload, uint32(request.Header.challenge))
+			payload = binary.LittleEndian.AppendUint32(payload, uint32(responseValue))
+			conn.connection.Write(append(payload, "short"...))
+		})
+
+		c := server.client(t, pw)
+		c.authorized = true
+		c.MaxResponseBytes = limit
+
+		expected := ErrInvalidRead
+		if 0 != limit {
+			expected = ErrResponseTooLarge
+		}
+
+		if _, err := c.Execute("status"); !errors.Is(err, expected) {
+			t.Log("Expected", expected, "with limit", limit, "got", err)
+			t.Fail()
+		}
+
+		c.Disconnect()
+		server.close()
+	}
+}
+
+func TestMockIdleTimeout(t *testing.T) {
+	listener := newMockListener(t, func(n int, server mockConn) {
+		if !server.acceptAuth(pw) {
+			return
+		}
+
+		for {
+			request, err := server.readPacket()
+			if nil != err {
+				return
+			}
+
+			server.respond(request.Header.challenge, responseValue, "hostname: mock")
+		}
+	})
+	defer listener.Close()
+
+	addr := listener.Addr().(*net.TCPAddr)
+
+	c := NewClient(addr.IP.String(), addr.Port, pw)
+	c.IdleTimeout = 100 * time.Millisecond
+
+	if err := c.Connect(); nil != err {
+		t.Fatal("Expected no error during connect", err)
+	}
+	defer c.Disconnect()
+
+	if _, err := c.Authorize(); nil != err {
+		t.Fatal("Expected no error during authorize", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	if !c.idleClosed.Load() {
+		t.Log("Expected the idle connection to be closed")
+		t.Fail()
+	}
+
+	if response, err := c.Execute("status"); nil != err || "hostname: mock" != response.Body {
+		t.Log("Expected the connection to be reopened", response, err)
+		t.Fail()
+	} else if 1 != c.Metrics().Reconnects {
+		t.Log("Expected one reconnect", c.Metrics())
+		t.Fail()
+	}
+}
+
+func TestMockSharedClient(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		conn.acceptAuth(pw)
+		conn.readPacket()
+	})
+	defer server.close()
+
+	addr := server.listener.Addr().(*net.TCPAddr)
+	shared := NewSharedClient(NewClient(addr.IP.String(), addr.Port, pw))
+
+	a, err := shared.Acquire()
+	if nil != err {
+		t.Fatal("Expected no error during the first acquire", err)
+	}
+
+	// The mock server accepts a single connection, so the second acquire
+	// must reuse the first's.
+	if b, err := shared.Acquire(); nil != err || a != b {
+		t.Fatal("Expected the second acquire to share the client", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := shared.Release(); nil != err {
+			t.Log("Expected no error during release", err)
+			t.Fail()
+		}
+	}
+
+	if err := shared.Release(); !errors.Is(err, ErrNotAcquired) {
+		t.Log("Expected ErrNotAcquired releasing once too often, got", err)
+		t.Fail()
+	}
+}
+
+func TestConformanceTest(t *testing.T) {
+	listener := newMockListener(t, func(n int, server mockConn) {
+		if !server.acceptAuth(pw) {
+			return
+		}
+
+		for {
+			request, err := server.readPacket()
+			if nil != err {
+				return
+			}
+
+			if request.Header.headerType == responseValue {
+				server.respond(request.Header.challenge, responseValue, "")
+				server.respond(request.Header.challenge, responseValue, "\x00\x01\x00\x00")
+			} else {
+				server.respond(request.Header.challenge, responseValue, request.Body)
+			}
+		}
+	})
+	defer listener.Close()
+
+	for _, result := range ConformanceTest(listener.Addr().String(), pw) {
+		if !result.Passed {
+			t.Log("Expected check", result.Check, "to pass", result.Deviation)
+			t.Fail()
+		}
+	}
+}
+
+func BenchmarkExecuteMultiPacket(b *testing.B) {
+	for _, buffered := range []bool{false, true} {
+		b.Run(map[bool]string{false: "unbuffered", true: "buffered"}[buffered], func(b *testing.B) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if nil != err {
+				b.Fatal("Expected no error during listen", err)
+			}
+			defer listener.Close()
+
+			go func() {
+				conn, err := listener.Accept()
+				if nil != err {
+					return
+				}
+				defer conn.Close()
+
+				server := mockConn{&Client{connection: conn, PaddingSize: packetPaddingSize}}
+
+				for {
+					request, err := server.readPacket()
+					if nil != err {
+						return
+					}
+
+					if request.Header.headerType == responseValue {
+						server.respond(request.Header.challenge, responseValue, "")
+						server.respond(request.Header.challenge, responseValue, "\x00\x01\x00\x00")
+					} else {
+						server.respond(request.Header.challenge, responseValue, "hostname: mock")
+					}
+				}
+			}()
+
+			addr := listener.Addr().(*net.TCPAddr)
+
+			c := NewClient(addr.IP.String(), addr.Port, pw)
+			if err := c.Connect(); nil != err {
+				b.Fatal("Expected no error during connect", err)
+			}
+			defer c.Disconnect()
+
+			c.authorized = true
+			c.BufferedWrites = buffered
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := c.ExecuteMultiPacket("status"); nil != err {
+					b.Fatal("Expected no error during execute", err)
+				}
+			}
+		})
+	}
+}
+
+func TestMockChallengeFunc(t *testing.T) {
+	challenges := make(chan int32, 2)
+
+	server := newMockServer(t, func(conn mockConn) {
+		for i := 0; i < 2; i++ {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			challenges <- request.Header.challenge
+			conn.respond(request.Header.challenge, responseValue, "")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	var next int32 = 41
+	c.authorized = true
+	c.ChallengeFunc = func() int32 {
+		next++
+		return next
+	}
+
+	for _, expected := range []int32{42, 43} {
+		if _, err := c.Execute("status"); nil != err {
+			t.Log("Expected no error during execute", err)
+			t.Fail()
+		} else if challenge := <-challenges; expected != challenge {
+			t.Log("Expected challenge", expected, "got", challenge)
+			t.Fail()
+		}
+	}
+}
+
+func TestMockChangeLevel(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		current := "de_dust2"
+		polls := 0
+
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			if strings.HasPrefix(request.Body, "changelevel ") {
+				conn.respond(request.Header.challenge, responseValue, "")
+				continue
+			}
+
+			// Report the new map from the second poll on.
+			if polls++; polls > 1 {
+				current = "de_inferno"
+			}
+
+			conn.respond(request.Header.challenge, responseValue, "\"host_map\" = \""+current+".bsp\"\n")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	if err := c.ChangeLevel("de_inferno", 5*time.Second); nil != err {
+		t.Log("Expected no error during the level change", err)
+		t.Fail()
+	}
+}
+
+func TestMockRequireProtocolRange(t *testing.T) {
+	for _, max := range []int{2, 5} {
+		server := newMockServer(t, func(conn mockConn) {
+			conn.respond(0, responseValue, "Protocol version 3")
+		})
+
+		addr := server.listener.Addr().(*net.TCPAddr)
+
+		c := NewClient(addr.IP.String(), addr.Port, pw)
+		c.RequireProtocolRange(1, max)
+
+		if err := c.Connect(); 5 == max && nil != err {
+			t.Log("Expected no error connecting to a supported server", err)
+			t.Fail()
+		} else if 2 == max && !errors.Is(err, ErrUnsupportedProtocol) {
+			t.Log("Expected ErrUnsupportedProtocol, got", err)
+			t.Fail()
+		}
+
+		c.Disconnect()
+		server.close()
+	}
+}
+
+func TestMockMaxReconnects(t *testing.T) {
+	listener := newMockListener(t, func(n int, server mockConn) {
+		server.acceptAuth(pw)
+		server.readPacket()
+	})
+	defer listener.Close()
+
+	addr := listener.Addr().(*net.TCPAddr)
+
+	c := NewClient(addr.IP.String(), addr.Port, pw)
+	c.MaxReconnects = 2
+
+	if err := c.Connect(); nil != err {
+		t.Fatal("Expected no error during connect", err)
+	}
+	defer c.Disconnect()
+
+	for i := 0; i < 2; i++ {
+		if err := c.reconnect(); nil != err {
+			t.Fatal("Expected no error within the budget", err)
+		}
+	}
+
+	if err := c.reconnect(); !errors.Is(err, ErrReconnectBudgetExhausted) {
+		t.Log("Expected ErrReconnectBudgetExhausted, got", err)
+		t.Fail()
+	}
+
+	if 2 != c.ReconnectAttempts() {
+		t.Log("Expected 2 reconnection attempts, got", c.ReconnectAttempts())
+		t.Fail()
+	}
+
+	c.ResetReconnects()
+
+	if err := c.reconnect(); nil != err {
+		t.Log("Expected no error after resetting the budget", err)
+		t.Fail()
+	}
+}
+
+func TestMockConsumeConnectBanner(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		conn.respond(0, responseValue, "Welcome to the mock server.")
+
+		if !conn.acceptAuth(pw) {
+			return
+		}
+
+		request, err := conn.readPacket()
+		if nil != err {
+			return
+		}
+
+		conn.respond(request.Header.challenge, responseValue, "hostname: mock")
+	})
+	defer server.close()
+
+	addr := server.listener.Addr().(*net.TCPAddr)
+
+	c := NewClient(addr.IP.String(), addr.Port, pw)
+	c.ConsumeConnectBanner = true
+
+	if err := c.Connect(); nil != err {
+		t.Fatal("Expected no error during connect", err)
+	}
+	defer c.Disconnect()
+
+	if banner := c.ConnectBanner(); nil == banner || "Welcome to the mock server." != banner.Body {
+		t.Log("Unexpected banner", banner)
+		t.Fail()
+	}
+
+	if _, err := c.Authorize(); nil != err {
+		t.Fatal("Expected no error during authorize", err)
+	}
+
+	if response, err := c.Execute("hostname"); nil != err || "hostname: mock" != response.Body {
+		t.Log("Unexpected response", response, err)
+		t.Fail()
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestMockExecuteTo(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			if request.Header.headerType == responseValue {
+				conn.respond(request.Header.challenge, responseValue, "")
+				conn.respond(request.Header.challenge, responseValue, "\x00\x01\x00\x00")
+			} else {
+				conn.respond(request.Header.challenge, responseValue, "first ")
+				conn.respond(request.Header.challenge, responseValue, "second")
+			}
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	var output strings.Builder
+
+	if response, err := c.ExecuteTo("cvarlist", &output); nil != err || "first second" != response.Body {
+		t.Log("Unexpected response", response, err)
+		t.Fail()
+	} else if "first second" != output.String() {
+		t.Log("Unexpected output", output.String())
+		t.Fail()
+	}
+
+	if _, err := c.ExecuteTo("cvarlist", failingWriter{}); !errors.Is(err, ErrResponseWrite) {
+		t.Log("Expected ErrResponseWrite, got", err)
+		t.Fail()
+	}
+}
+
+// netWriter fails like a writer forwarding to a broken connection.
+type netWriter struct{}
+
+func (netWriter) Write(b []byte) (int, error) {
+	return 0, &net.OpError{Op: "write", Net: "tcp", Err: errors.New("broken pipe")}
+}
+
+func TestMockExecuteToWriterSentinel(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			if request.Header.headerType == responseValue {
+				conn.respond(request.Header.challenge, responseValue, "")
+				conn.respond(request.Header.challenge, responseValue, "\x00\x01\x00\x00")
+			} else {
+				conn.respond(request.Header.challenge, responseValue, "cvars")
+			}
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	if _, err := c.ExecuteTo("cvarlist", netWriter{}); !errors.Is(err, ErrResponseWrite) {
+		t.Log("Expected ErrResponseWrite, got", err)
+		t.Fail()
+	}
+
+	// The writer's failure says nothing of the server's support.
+	if SentinelUnknown != c.SentinelSupport() {
+		t.Log("Expected the sentinel's support still unknown, got", c.SentinelSupport())
+		t.Fail()
+	}
+}
+
+// eventWriter records each write as an event.
+type eventWriter struct {
+	events *[]string
+}
+
+func (this eventWriter) Write(b []byte) (int, error) {
+	*this.events = append(*this.events, "write "+string(b))
+	return len(b), nil
+}
+
+func TestMockOnFragment(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			if request.Header.headerType == responseValue {
+				conn.respond(request.Header.challenge, responseValue, "")
+				conn.respond(request.Header.challenge, responseValue, "\x00\x01\x00\x00")
+			} else {
+				for _, fragment := range []string{"a", "b", "c"} {
+					conn.respond(request.Header.challenge, responseValue, fragment)
+				}
+			}
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	var events []string
+
+	c.OnFragment = func(index int, body string) {
+		events = append(events, fmt.Sprint("fragment ", index, " ", body))
+	}
+
+	if _, err := c.ExecuteTo("cvarlist", eventWriter{&events}); nil != err {
+		t.Fatal("Expected no error during execute", err)
+	}
+
+	expected := []string{"fragment 0 a", "write a", "fragment 1 b", "write b", "fragment 2 c", "write c"}
+
+	if !slices.Equal(expected, events) {
+		t.Log("Expected each fragment reported before it's written", events)
+		t.Fail()
+	}
+}
+
+func TestMockLingerSeconds(t *testing.T) {
+	for _, linger := range []int{-1, 0} {
+		closed := make(chan error, 1)
+
+		server := newMockServer(t, func(conn mockConn) {
+			_, err := conn.connection.Read(make([]byte, 1))
+			closed <- err
+		})
+
+		c := server.client(t, pw)
+		c.LingerSeconds = linger
+		c.Disconnect()
+
+		// Without lingering, the connection's reset rather than closed.
+		if err := <-closed; 0 == linger && !errors.Is(err, syscall.ECONNRESET) {
+			t.Log("Expected the connection reset, got", err)
+			t.Fail()
+		} else if 0 != linger && io.EOF != err {
+			t.Log("Expected the connection closed, got", err)
+			t.Fail()
+		}
+
+		server.close()
+	}
+}
+
+func TestMockConn(t *testing.T) {
+	listener := newMockListener(t, func(n int, server mockConn) {
+		if !server.acceptAuth(pw) {
+			return
+		}
+
+		server.readPacket()
+	})
+	defer listener.Close()
+
+	c := NewClient("", 0, pw)
+	c.Addresses = []string{listener.Addr().String()}
+	defer c.Disconnect()
+
+	if nil != c.Conn() {
+		t.Log("Expected no connection before connecting")
+		t.Fail()
+	}
+
+	if err := c.Connect(); nil != err {
+		t.Fatal("Expected no error during connect", err)
+	}
+
+	first, ok := c.Conn().(*net.TCPConn)
+	if !ok {
+		t.Fatal("Expected a *net.TCPConn, got", c.Conn())
+	} else if listener.Addr().String() != first.RemoteAddr().String() {
+		t.Log("Expected the connection to the listener, got", first.RemoteAddr())
+		t.Fail()
+	} else if err := first.SetNoDelay(false); nil != err {
+		t.Log("Expected no error setting a socket option", err)
+		t.Fail()
+	}
+
+	if err := c.reconnect(); nil != err {
+		t.Fatal("Expected no error reconnecting", err)
+	} else if c.Conn() == net.Conn(first) {
+		t.Log("Expected the connection replaced on reconnecting")
+		t.Fail()
+	}
+}
+
+func TestMockStripColors(t *testing.T) {
+	const colored = "\x01[SM] \x04Player\x01 was {green}kicked{default}"
+
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			conn.respond(request.Header.challenge, responseValue, colored)
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	if response, err := c.Execute("sm_kick player"); nil != err || colored != response.Body {
+		t.Log("Expected the colors kept by default", response, err)
+		t.Fail()
+	}
+
+	c.StripColors = true
+
+	if response, err := c.Execute("sm_kick player"); nil != err || "[SM] Player was kicked" != response.Body {
+		t.Log("Expected the colors stripped", response, err)
+		t.Fail()
+	}
+}
+
+func TestMockLenientTrim(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			// Terminated twice over.
+			conn.respond(request.Header.challenge, responseValue, "hostname: mock\x00")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	if response, err := c.Execute("hostname"); nil != err || "hostname: mock\x00" != response.Body {
+		t.Log("Expected only the terminator and padding trimmed by default", response, err)
+		t.Fail()
+	}
+
+	c.LenientTrim = true
+
+	if response, err := c.Execute("hostname"); nil != err || "hostname: mock" != response.Body {
+		t.Log("Expected every trailing null trimmed", response, err)
+		t.Fail()
+	}
+}
+
+func TestMockCvarList(t *testing.T) {
+	// The listing's split mid line over two fragments.
+	fragments := []string{
+		"cvar list\n" +
+			"--------------\n" +
+			"_autosave                                : cmd      :                  : Autosave\n" +
+			"sv_cheats                                : 0        : , \"nf\", ",
+		"\"rep\"    : Allow cheats on server\n" +
+			"--------------\n" +
+			"  2 total convars/concommands\n",
+	}
+
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			if request.Header.headerType == responseValue {
+				conn.respond(request.Header.challenge, responseValue, "")
+				conn.respond(request.Header.challenge, responseValue, "\x00\x01\x00\x00")
+			} else if "cvarlist" == request.Body {
+				for _, fragment := range fragments {
+					conn.respond(request.Header.challenge, responseValue, fragment)
+				}
+			}
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	cvars, err := c.CvarList()
+	if nil != err {
+		t.Fatal("Expected no error listing cvars", err)
+	}
+
+	if 2 != len(cvars) || "_autosave" != cvars[0].Name || "sv_cheats" != cvars[1].Name || "Allow cheats on server" != cvars[1].Help {
+		t.Log("Unexpected cvars", cvars)
+		t.Fail()
+	}
+}
+
+func TestMockRemoteAddr(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		conn.readPacket()
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+
+	if addr := c.RemoteAddr(); nil == addr || server.listener.Addr().String() != addr.String() {
+		t.Log("Expected the listener's address, got", addr)
+		t.Fail()
+	}
+
+	if nil == c.LocalAddr() {
+		t.Log("Expected a local address")
+		t.Fail()
+	}
+
+	c.Disconnect()
+
+	if nil != c.RemoteAddr() || nil != c.LocalAddr() {
+		t.Log("Expected no addresses once disconnected")
+		t.Fail()
+	}
+}
+
+func TestMockFragmentTimeout(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		request, err := conn.readPacket()
+		if nil != err {
+			return
+		}
+
+		// Never mirror the end marker.
+		conn.respond(request.Header.challenge, responseValue, "partial")
+		conn.readPacket()
+		conn.readPacket()
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+	c.FragmentTimeout = 100 * time.Millisecond
+
+	response, err := c.ExecuteMultiPacket("cvarlist")
+	if !errors.Is(err, ErrIncompleteResponse) {
+		t.Log("Expected ErrIncompleteResponse, got", err)
+		t.Fail()
+	} else if nil == response || "partial" != response.Body {
+		t.Log("Expected the partial response", response)
+		t.Fail()
+	}
+}
+
+func TestMockSay(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			var body string
+
+			switch request.Body {
+			case `say "Restarting; back soon, 'promise'"`:
+			case `sm_psay "#2" "Hi"`:
+			case `sm_psay "#3" "Hi"`:
+				body = "[SM] No matching client was found.\n"
+			default:
+				body = "Unknown command \"" + strings.Fields(request.Body)[0] + "\"\n"
+			}
+
+			conn.respond(request.Header.challenge, responseValue, body)
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	if err := c.Say(`Restarting; back soon, "promise"`); nil != err {
+		t.Log("Expected no error during say", err)
+		t.Fail()
+	}
+
+	if err := c.SayTo("#2", "Hi"); nil != err {
+		t.Log("Expected no error during say to a player", err)
+		t.Fail()
+	}
+
+	if err := c.SayTo("#3", "Hi"); !errors.Is(err, ErrPlayerNotFound) {
+		t.Log("Expected ErrPlayerNotFound, got", err)
+		t.Fail()
+	}
+
+	if err := c.SayTo("#2", "Hi\n"); nil != err {
+		t.Log("Expected the newline stripped from the message", err)
+		t.Fail()
+	}
+
+	// The mock doesn't recognize any other message.
+	if err := c.Say("Other"); !errors.Is(err, ErrUnknownCommand) {
+		t.Log("Expected ErrUnknownCommand, got", err)
+		t.Fail()
+	}
+}
+
+func TestConnectRetries(t *testing.T) {
+	// Find a free port, then start listening on it only after the first
+	// dials have failed.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal("Expected no error during listen", err)
+	}
+
+	addr := listener.Addr().String()
+	listener.Close()
+
+	listening := make(chan net.Listener, 1)
+
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+
+		listener, err := net.Listen("tcp", addr)
+		if nil != err {
+			close(listening)
+			return
+		}
+
+		listening <- listener
+	}()
+
+	c := NewClient("", 0, pw)
+	c.Addresses = []string{addr}
+	c.ConnectRetries = 20
+	c.ConnectRetryDelay = 50 * time.Millisecond
+
+	err = c.Connect()
+
+	if listener, ok := <-listening; !ok {
+		t.Skip("Port was taken before listening on it again")
+	} else {
+		defer listener.Close()
+	}
+
+	if nil != err {
+		t.Log("Expected the connection established by a retry", err)
+		t.Fail()
+	}
+
+	c.Disconnect()
+}
+
+func TestMockWithContext(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		// Never respond, so the command is in flight until cancelled.
+		for {
+			if _, err := conn.readPacket(); nil != err {
+				return
+			}
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.WithContext(ctx)
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	if _, err := c.Execute("status"); nil == err {
+		t.Log("Expected the command in flight aborted")
+		t.Fail()
+	}
+
+	if _, err := c.Execute("status"); !errors.Is(err, ErrClientShutdown) || !errors.Is(err, context.Canceled) {
+		t.Log("Expected ErrClientShutdown wrapping context.Canceled, got", err)
+		t.Fail()
+	}
+
+	if err := c.Connect(); !errors.Is(err, context.Canceled) {
+		t.Log("Expected connecting to fail with context.Canceled, got", err)
+		t.Fail()
+	}
+
+	c.WithContext(context.Background())
+
+	if err := c.Connect(); nil != err {
+		t.Log("Expected no error connecting with a live context", err)
+		t.Fail()
+	}
+}
+
+func TestMockWithContextReplacedWhileCancelling(t *testing.T) {
+	received := make(chan struct{}, 2)
+
+	server := newMockServer(t, func(conn mockConn) {
+		// Never respond, so the commands are in flight until cancelled.
+		for {
+			if _, err := conn.readPacket(); nil != err {
+				return
+			}
+
+			received <- struct{}{}
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.WithContext(ctx)
+
+	// One command holds the command lock while an async one waits for it,
+	// to check the context once the first's aborted.
+	aborted := make(chan struct{})
+
+	go func() {
+		c.Execute("status")
+		close(aborted)
+	}()
+
+	<-received
+	queued := c.ExecuteAsync("queued")
+
+	replaced := make(chan struct{})
+
+	// Replace the context once cancelling's underway, as the async command
+	// takes the command lock and checks the context.
+	go func() {
+		cancel()
+		<-aborted
+		c.WithContext(context.Background())
+		close(replaced)
+	}()
+
+	select {
+	case <-replaced:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the context replaced while cancelling an async command")
+	}
+
+	if result := <-queued; nil == result.Err {
+		t.Log("Expected the queued command to fail once cancelled", result)
+		t.Fail()
+	}
+}
+
+func TestMockMinCommandInterval(t *testing.T) {
+	const interval = 50 * time.Millisecond
+
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			conn.respond(request.Header.challenge, responseValue, request.Body)
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+	c.MinCommandInterval = interval
+
+	var sent []time.Time
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Execute("status"); nil != err {
+			t.Fatal("Expected no error during execute", err)
+		}
+
+		sent = append(sent, time.Now())
+	}
+
+	for i := 1; i < len(sent); i++ {
+		if gap := sent[i].Sub(sent[i-1]); gap < interval {
+			t.Log("Expected commands spaced by the minimum interval, got", gap)
+			t.Fail()
+		}
+	}
+}
+
+// blockingLimiter never allows a command, waiting until ctx is done.
+type blockingLimiter struct{}
+
+func (blockingLimiter) Wait(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestMockThrottleContext(t *testing.T) {
+	for name, throttle := range map[string]func(*Client){
+		"limiter":  func(c *Client) { c.Limiter = blockingLimiter{} },
+		"interval": func(c *Client) { c.MinCommandInterval = time.Hour },
+	} {
+		server := newMockServer(t, func(conn mockConn) {
+			conn.readPacket()
+		})
+
+		c := server.client(t, pw)
+		c.authorized = true
+		c.lastCommand = time.Now()
+		throttle(c)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		c.WithContext(ctx)
+
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		results := make(chan error, 1)
+		go func() {
+			_, err := c.Execute("status")
+			results <- err
+		}()
+
+		select {
+		case err := <-results:
+			if !errors.Is(err, ErrClientShutdown) || !errors.Is(err, context.Canceled) {
+				t.Log(name, "expected ErrClientShutdown wrapping context.Canceled, got", err)
+				t.Fail()
+			}
+		case <-time.After(2 * time.Second):
+			t.Log(name, "expected the wait aborted once the context was cancelled")
+			t.Fail()
+		}
+
+		c.Disconnect()
+		server.close()
+	}
+}
+
+func TestMockWaitForCvarContext(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			conn.respond(request.Header.challenge, responseValue, "\"sv_cheats\" = \"0\"\n")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+
+	if err := c.WaitForCvarContext(ctx, "sv_cheats", "1", 10*time.Second); !errors.Is(err, context.Canceled) {
+		t.Log("Expected context.Canceled, got", err)
+		t.Fail()
+	} else if elapsed := time.Since(start); elapsed >= cvarPollInterval {
+		t.Log("Expected cancellation between polls to be immediate, took", elapsed)
+		t.Fail()
+	}
+}
+
+// newCvarServer starts a mock server reporting the cvars' values, and
+// unknown commands for any others.
+func newCvarServer(t *testing.T, cvars map[string]string) *mockServer {
+	return newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			body := "Unknown command \"" + request.Body + "\"\n"
+			if value, ok := cvars[request.Body]; ok {
+				body = "\"" + request.Body + "\" = \"" + value + "\"\n"
+			}
+
+			conn.respond(request.Header.challenge, responseValue, body)
+		}
+	})
+}
+
+func TestMockDiffCvars(t *testing.T) {
+	serverA := newCvarServer(t, map[string]string{"sv_cheats": "0", "mp_timelimit": "30", "sm_only": "1"})
+	defer serverA.close()
+	serverB := newCvarServer(t, map[string]string{"sv_cheats": "0", "mp_timelimit": "45"})
+	defer serverB.close()
+
+	a, b := serverA.client(t, pw), serverB.client(t, pw)
+	defer a.Disconnect()
+	defer b.Disconnect()
+
+	a.authorized, b.authorized = true, true
+
+	diff, err := DiffCvars(a, b, []string{"sv_cheats", "mp_timelimit", "sm_only", "nowhere"})
+	if nil != err {
+		t.Fatal("Expected no error diffing the cvars", err)
+	}
+
+	expected := map[string][2]string{
+		"mp_timelimit": {"30", "45"},
+		"sm_only":      {"1", ""},
+	}
+
+	if !maps.Equal(diff, expected) {
+		t.Log("Unexpected differences", diff)
+		t.Fail()
+	}
+}
+
+func TestMockExecuteLimited(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			if request.Header.headerType == responseValue {
+				conn.respond(request.Header.challenge, responseValue, "")
+				conn.respond(request.Header.challenge, responseValue, "\x00\x01\x00\x00")
+			} else {
+				for _, fragment := range []string{"a\nb\n", "c\nd\n", "e\n"} {
+					conn.respond(request.Header.challenge, responseValue, fragment)
+				}
+			}
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	if lines, truncated, err := c.ExecuteLimited("cvarlist", 3); nil != err || !truncated || !slices.Equal(lines, []string{"a", "b", "c"}) {
+		t.Log("Unexpected limited lines", lines, truncated, err)
+		t.Fail()
+	}
+
+	if lines, truncated, err := c.ExecuteLimited("cvarlist", 5); nil != err || truncated || 5 != len(lines) {
+		t.Log("Expected every line after the discarded fragments", lines, truncated, err)
+		t.Fail()
+	}
+
+	if lines, truncated, err := c.ExecuteLimited("cvarlist", -1); nil != err || !truncated || 0 != len(lines) {
+		t.Log("Expected no lines with a negative limit", lines, truncated, err)
+		t.Fail()
+	}
+}
+
+func TestMockExpectAck(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		if !conn.acceptAuth(pw) {
+			return
+		}
+
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			conn.respond(request.Header.challenge, responseValue, "OK")
+			conn.respond(request.Header.challenge, responseValue, "hostname: mock")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.ExpectAck = true
+
+	if _, err := c.Authorize(); nil != err {
+		t.Fatal("Expected no error during authorize", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if response, err := c.Execute("hostname"); nil != err || "hostname: mock" != response.Body {
+			t.Log("Expected the response after the ack", response, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestMockAuthRenewInterval(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		conn.acceptAuth(pw)
+
+		// Reject renewals, as if the password changed.
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			conn.respond(request.Header.challenge, responseValue, "")
+			conn.respond(-1, authResponse, "")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.AuthRenewInterval = 50 * time.Millisecond
+
+	if _, err := c.Authorize(); nil != err {
+		t.Fatal("Expected no error during authorize", err)
+	}
+
+	select {
+	case err := <-c.AuthRenewErrors():
+		if !errors.Is(err, ErrInvalidChallenge) {
+			t.Log("Expected the rejected renewal's ErrInvalidChallenge, got", err)
+			t.Fail()
+		}
+	case <-time.After(2 * time.Second):
+		t.Log("Expected a renewal to fail")
+		t.Fail()
+	}
+}
+
+func TestMockExecuteMatch(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			conn.respond(request.Header.challenge, responseValue, "hostname: mock\nmap     : de_dust2 at: 0 x, 0 y, 0 z\n")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	if submatches, err := c.ExecuteMatch("status", regexp.MustCompile(`(?m)^map\s*: (\S+)`)); nil != err || !slices.Equal(submatches, []string{"de_dust2"}) {
+		t.Log("Unexpected submatches", submatches, err)
+		t.Fail()
+	}
+
+	if _, err := c.ExecuteMatch("status", regexp.MustCompile(`^players : (\d+)`)); !errors.Is(err, ErrNoMatch) {
+		t.Log("Expected ErrNoMatch, got", err)
+		t.Fail()
+	}
+}
+
+func TestMockMultiPool(t *testing.T) {
+	var servers []*mockServer
+
+	for _, hostname := range []string{"a", "b"} {
+		server := newMockServer(t, func(conn mockConn) {
+			if !conn.acceptAuth(pw) {
+				return
+			}
+
+			for {
+				request, err := conn.readPacket()
+				if nil != err {
+					return
+				}
+
+				conn.respond(request.Header.challenge, responseValue, "hostname: "+hostname)
+			}
+		})
+		defer server.close()
+
+		servers = append(servers, server)
+	}
+
+	multi := NewMultiPool(pw, 1, 1, 100*time.Millisecond)
+	defer multi.Close()
+
+	for i, hostname := range []string{"a", "b"} {
+		response, err := multi.Execute(servers[i].listener.Addr().String(), "hostname")
+		if nil != err || "hostname: "+hostname != response.Body {
+			t.Log("Unexpected response", response, err)
+			t.Fail()
+		}
+
+		// The second server's pool evicts the first's, within the cap.
+		if 1 != multi.Pools() {
+			t.Log("Expected a single pool, got", multi.Pools())
+			t.Fail()
+		}
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	if 0 != multi.Pools() {
+		t.Log("Expected the idle pool evicted, got", multi.Pools())
+		t.Fail()
+	}
+}
+
+func TestMockMultiPoolSize(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		if !conn.acceptAuth(pw) {
+			return
+		}
+
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			conn.respond(request.Header.challenge, responseValue, "hostname: mock")
+		}
+	})
+	defer server.close()
+
+	multi := NewMultiPool(pw, 0, 1, 0)
+	defer multi.Close()
+
+	results := make(chan Result, 1)
+	go func() {
+		response, err := multi.Execute(server.listener.Addr().String(), "hostname")
+		results <- Result{response, err}
+	}()
+
+	select {
+	case result := <-results:
+		if nil != result.Err || "hostname: mock" != result.Packet.Body {
+			t.Log("Unexpected response", result)
+			t.Fail()
+		}
+	case <-time.After(2 * time.Second):
+		t.Log("Expected a pool of size zero to hold one connection")
+		t.Fail()
+	}
+}
+
+func TestMockServerChallenge(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			// Alter the challenge echoed.
+			conn.respond(request.Header.challenge+1, responseValue, "hostname: mock")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+	c.CorrelateByOrder = true
+
+	response, err := c.Execute("hostname")
+	if nil != err {
+		t.Fatal("Expected no error correlating by order", err)
+	}
+
+	if response.ServerChallenge() != response.RequestChallenge()+1 {
+		t.Log("Expected the altered challenge echoed", response.ServerChallenge(), response.RequestChallenge())
+		t.Fail()
+	}
+}
+
+func TestMockSentinelFallback(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			// Ignore the sentinel, never mirroring it.
+			if request.Header.headerType == exec {
+				conn.respond(request.Header.challenge, responseValue, "hostname: mock")
+			}
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+	c.FragmentTimeout = 100 * time.Millisecond
+
+	if _, err := c.ExecuteMultiPacket("hostname"); !errors.Is(err, ErrIncompleteResponse) {
+		t.Log("Expected ErrIncompleteResponse detecting the sentinel unsupported, got", err)
+		t.Fail()
+	}
+
+	if SentinelUnsupported != c.SentinelSupport() {
+		t.Log("Expected the sentinel unsupported, got", c.SentinelSupport())
+		t.Fail()
+	}
+
+	if response, err := c.ExecuteMultiPacket("hostname"); nil != err || "hostname: mock" != response.Body {
+		t.Log("Expected the response assembled without a sentinel", response, err)
+		t.Fail()
+	}
+}
+
+func TestMockSendRaw(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		request := make([]byte, 5)
+		if _, err := io.ReadFull(conn.connection, request); nil != err {
+			return
+		}
+
+		conn.connection.Write([]byte("echo:"))
+		conn.connection.Write(request)
+		conn.readPacket()
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	if response, err := c.SendRaw([]byte("\x01\x02\x03\x04\x05")); nil != err || "echo:\x01\x02\x03\x04\x05" != string(response) {
+		t.Log("Unexpected raw response", response, err)
+		t.Fail()
+	}
+}
+
+func TestMockDetectGame(t *testing.T) {
+	versions := []string{
+		"Protocol version 24 [1234/1234]\nExe version 8412345 (tf)\nExe build: 12:34:56 Jan 12 2023 (8412345) (440)\n",
+		"Protocol version 24\nExe build: 12:34:56 Jan 12 2023 (1234) (550)\n",
+		"Protocol version 24\nExe version 1.0.0.0 (mymod)\nExe build: 12:34:56 Jan 12 2023 (1234) (123456)\n",
+	}
+	expected := []Game{GameTF2, GameL4D2, GameUnknown}
+
+	for i, version := range versions {
+		server := newMockServer(t, func(conn mockConn) {
+			for {
+				request, err := conn.readPacket()
+				if nil != err {
+					return
+				}
+
+				conn.respond(request.Header.challenge, responseValue, version)
+			}
+		})
+
+		c := server.client(t, pw)
+		c.authorized = true
+
+		game, err := c.DetectGame()
+		if game != expected[i] {
+			t.Log("Expected", expected[i], "got", game, err)
+			t.Fail()
+		} else if GameUnknown == game && (!errors.Is(err, ErrUnknownGame) || !strings.Contains(err.Error(), `"mymod", app ID 123456`)) {
+			t.Log("Expected ErrUnknownGame naming the identifiers, got", err)
+			t.Fail()
+		} else if GameUnknown != game && nil != err {
+			t.Log("Expected no error detecting", game, err)
+			t.Fail()
+		}
+
+		c.Disconnect()
+		server.close()
+	}
+}
+
+func TestMockReadDelay(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			if request.Header.headerType == responseValue {
+				conn.respond(request.Header.challenge, responseValue, "")
+				conn.respond(request.Header.challenge, responseValue, "\x00\x01\x00\x00")
+			} else {
+				conn.respond(request.Header.challenge, responseValue, "ok")
+			}
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+	c.ReadDelay = 100 * time.Millisecond
+
+	commands := map[string]func() error{
+		"Execute": func() error {
+			_, err := c.Execute("status")
+			return err
+		},
+		"ExecuteMultiPacket": func() error {
+			_, err := c.ExecuteMultiPacket("cvarlist")
+			return err
+		},
+		"ExecuteInto": func() error {
+			_, err := c.ExecuteInto("status", make([]byte, 16))
+			return err
+		},
+		"RoundTrip": func() error {
+			_, err := c.RoundTrip(newPacket(1, exec, "status", packetPaddingSize))
+			return err
+		},
+	}
+
+	for name, command := range commands {
+		start := time.Now()
+
+		if err := command(); nil != err {
+			t.Log("Expected no error from", name, err)
+			t.Fail()
+		} else if elapsed := time.Since(start); elapsed < c.ReadDelay {
+			t.Log("Expected", name, "to wait for ReadDelay, took", elapsed)
+			t.Fail()
+		}
+	}
+}
+
+func TestMockGetCvarInjection(t *testing.T) {
+	received := make(chan string, 4)
+
+	server := newMockServer(t, func(conn mockConn) {
+		defer close(received)
+
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			received <- request.Body
+			conn.respond(request.Header.challenge, responseValue, "")
+		}
+	})
+
+	c := server.client(t, pw)
+	c.authorized = true
+
+	if _, err := c.GetCvar("sv_cheats; quit"); !errors.Is(err, ErrUnsafeCommand) {
+		t.Log("Expected ErrUnsafeCommand getting an injected cvar, got", err)
+		t.Fail()
+	}
+
+	if _, err := c.SetCvar("sv_cheats; quit", "1"); !errors.Is(err, ErrUnsafeCommand) {
+		t.Log("Expected ErrUnsafeCommand setting an injected cvar, got", err)
+		t.Fail()
+	}
+
+	c.Disconnect()
+	server.close()
+
+	for body := range received {
+		t.Log("Expected nothing written, server received", body)
+		t.Fail()
+	}
+}
+
+func TestMockExecuteAsync(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			conn.respond(request.Header.challenge, responseValue, "echo "+request.Body)
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	var results []<-chan Result
+
+	for _, command := range []string{"one", "two", "three"} {
+		results = append(results, c.ExecuteAsync(command))
+	}
+
+	for i, command := range []string{"one", "two", "three"} {
+		if result := <-results[i]; nil != result.Err || "echo "+command != result.Packet.Body {
+			t.Log("Unexpected result of", command, result)
+			t.Fail()
+		}
+	}
+}
+
+func TestMockExecuteAsyncDisconnect(t *testing.T) {
+	received := make(chan struct{})
+
+	server := newMockServer(t, func(conn mockConn) {
+		// Never respond, so the command hangs without a Timeout.
+		if _, err := conn.readPacket(); nil != err {
+			return
+		}
+
+		close(received)
+		conn.readPacket()
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	c.authorized = true
+
+	hung := c.ExecuteAsync("hang")
+	queued := c.ExecuteAsync("queued")
+
+	<-received
+
+	disconnected := make(chan error)
+	go func() { disconnected <- c.Disconnect() }()
+
+	select {
+	case err := <-disconnected:
+		if nil != err {
+			t.Log("Expected no error during disconnect", err)
+			t.Fail()
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Disconnect to abort the hung command")
+	}
+
+	if result := <-hung; nil == result.Err {
+		t.Log("Expected the hung command to fail", result)
+		t.Fail()
+	}
+
+	if result := <-queued; !errors.Is(result.Err, ErrClientDisconnected) {
+		t.Log("Expected the queued command to fail with ErrClientDisconnected, got", result.Err)
+		t.Fail()
+	}
+}
+
+func TestMockThrottlePatterns(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			body := strings.TrimPrefix(request.Body, "echo ")
+			if "status" == request.Body {
+				body = "Flood protection: too many commands.\n"
+			}
+
+			conn.respond(request.Header.challenge, responseValue, body+"\n")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	// Detection is off by default, so bodies merely mentioning a pattern
+	// aren't mistaken for throttling.
+	if echoed, err := c.Echo("too many commands"); nil != err || "too many commands" != echoed {
+		t.Log("Expected no error echoing a pattern by default", echoed, err)
+		t.Fail()
+	}
+
+	c.ThrottlePatterns = slices.Clone(DefaultThrottlePatterns)
+
+	if _, err := c.Execute("status"); !errors.Is(err, ErrRateLimited) {
+		t.Log("Expected ErrRateLimited once opted in, got", err)
+		t.Fail()
+	}
+}
+
+func TestMockFailedRedialUnauthorizedExec(t *testing.T) {
+	listener := newMockListener(t, func(n int, server mockConn) {
+		server.readPacket()
+	})
+
+	c := NewClient("", 0, pw)
+	c.Addresses = []string{listener.Addr().String()}
+	c.AllowUnauthorizedExec = true
+	defer c.Disconnect()
+
+	if err := c.Connect(); nil != err {
+		t.Fatal("Expected no error during connect", err)
+	}
+
+	listener.Close()
+
+	if err := c.reconnect(); nil == err {
+		t.Fatal("Expected reconnecting to a closed listener to fail")
+	}
+
+	// Without a connection, commands fail rather than panic.
+	if _, err := c.Execute("status"); !errors.Is(err, ErrNotConnected) {
+		t.Log("Expected ErrNotConnected after a failed reconnection, got", err)
+		t.Fail()
+	}
+}
+
+func TestMockRedialChecks(t *testing.T) {
+	// The server's upgraded to an unsupported protocol on the second
+	// connection.
+	listener := newMockListener(t, func(n int, server mockConn) {
+		protocol := 3 + 6*n
+		server.respond(0, responseValue, fmt.Sprint("Protocol version ", protocol))
+
+		if !server.acceptAuth(pw) {
+			return
+		}
+
+		for {
+			request, err := server.readPacket()
+			if nil != err {
+				return
+			}
+
+			server.respond(request.Header.challenge, responseValue, fmt.Sprint("Protocol version ", protocol, "\n"))
+		}
+	})
+	defer listener.Close()
+
+	addr := listener.Addr().(*net.TCPAddr)
+
+	c := NewClient(addr.IP.String(), addr.Port, pw)
+	c.RequireProtocolRange(1, 5)
+
+	if err := c.Connect(); nil != err {
+		t.Fatal("Expected no error during connect", err)
+	}
+	defer c.Disconnect()
+
+	if _, err := c.Authorize(); nil != err {
+		t.Fatal("Expected no error during authorize", err)
+	} else if version, err := c.ServerVersion(); nil != err || 3 != version.Protocol {
+		t.Fatal("Unexpected version", version, err)
+	}
+
+	if err := c.reconnect(); !errors.Is(err, ErrUnsupportedProtocol) {
+		t.Log("Expected ErrUnsupportedProtocol reconnecting to an unsupported server, got", err)
+		t.Fail()
+	}
+
+	if nil != c.version.Load() {
+		t.Log("Expected the cached version forgotten on reconnecting")
+		t.Fail()
+	}
+}
+
+func TestMockPersistentSession(t *testing.T) {
+	authorized := make(chan bool, 4)
+
+	// Each connection's closed after its first keep alive, expiring the
+	// session.
+	listener := newMockListener(t, func(n int, server mockConn) {
+		ok := server.acceptAuth(pw)
+		authorized <- ok
+
+		if ok {
+			server.readPacket()
+		}
+	})
+	defer listener.Close()
+
+	addr := listener.Addr().(*net.TCPAddr)
+
+	for password, expected := range map[string]error{pw: nil, "wrong": ErrInvalidChallenge} {
+		c := NewClient(addr.IP.String(), addr.Port, password)
+
+		if err := c.Connect(); nil != err {
+			t.Fatal("Expected no error during connect", err)
+		} else if _, err = c.Authorize(); (nil == err) != (nil == expected) {
+			t.Fatal("Unexpected authorize result", err)
+		}
+
+		<-authorized
+
+		errs := c.PersistentSession(password, 50*time.Millisecond)
+
+		if nil == expected {
+			select {
+			case ok := <-authorized:
+				if !ok {
+					t.Log("Expected the session to reauthorize with the password")
+					t.Fail()
+				}
+			case err := <-errs:
+				t.Log("Expected the session to reconnect, got", err)
+				t.Fail()
+			case <-time.After(2 * time.Second):
+				t.Log("Expected the session to reconnect")
+				t.Fail()
+			}
+		} else if err := <-errs; !errors.Is(err, expected) {
+			t.Log("Expected", expected, "reauthorizing with the wrong password, got", err)
+			t.Fail()
+		}
+
+		c.Disconnect()
+	}
+}
+
+func TestMockExecuteInto(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			conn.respond(request.Header.challenge, responseValue, "hostname: mock")
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	buf := make([]byte, 64)
+
+	if n, err := c.ExecuteInto("status", buf); nil != err || "hostname: mock" != string(buf[:n]) {
+		t.Log("Unexpected body read into the buffer", string(buf[:n]), err)
+		t.Fail()
+	}
+
+	if n, err := c.ExecuteInto("status", buf[:4]); !errors.Is(err, io.ErrShortBuffer) || len("hostname: mock")+2 != n || "host" != string(buf[:4]) {
+		t.Log("Expected io.ErrShortBuffer with the length needed", n, err)
+		t.Fail()
+	}
+
+	c.MaxResponseBytes = 4
+
+	if _, err := c.ExecuteInto("status", buf); !errors.Is(err, ErrResponseTooLarge) {
+		t.Log("Expected ErrResponseTooLarge over MaxResponseBytes, got", err)
+		t.Fail()
+	}
+}
+
+func TestMockRetryOnChallengeMismatch(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		seen := make(map[string]bool)
+
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			// Answer each command with a stray response first, as if to
+			// an earlier command, then its own once it's sent again.
+			challenge := request.Header.challenge
+			if !seen[request.Body] {
+				challenge++
+			}
+
+			seen[request.Body] = true
+			conn.respond(challenge, responseValue, request.Body)
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	if _, err := c.Execute("first"); !errors.Is(err, ErrInvalidChallenge) {
+		t.Log("Expected ErrInvalidChallenge without retrying, got", err)
+		t.Fail()
+	}
+
+	c.RetryOnChallengeMismatch = true
+
+	if response, err := c.Execute("second"); nil != err || "second" != response.Body {
+		t.Log("Expected the retried command's own response", response, err)
+		t.Fail()
+	}
+}
+
+func TestMockDrainDeadline(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		request, err := conn.readPacket()
+		if nil != err {
+			return
+		}
+
+		// A stray response, then stray bytes for as long as the
+		// connection's open, never quiet long enough to finish draining.
+		conn.respond(request.Header.challenge+1, responseValue, request.Body)
+
+		for {
+			if _, err = conn.connection.Write([]byte{0}); nil != err {
+				return
+			}
+
+			time.Sleep(drainTimeout / 4)
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+	c.RetryOnChallengeMismatch = true
+	c.Timeout = 300 * time.Millisecond
+
+	start := time.Now()
+
+	if _, err := c.Execute("status"); nil == err {
+		t.Log("Expected the command to fail once its deadline passed")
+		t.Fail()
+	}
+
+	if elapsed := time.Since(start); elapsed > c.Timeout+drainTimeout {
+		t.Log("Expected draining bounded by the command's deadline, took", elapsed)
+		t.Fail()
+	}
+}
+
+func TestMockReplayQueuedOnReconnect(t *testing.T) {
+	for _, replay := range []bool{true, false} {
+		// The first connection drops on the first command; later ones
+		// answer every command.
+		listener := newMockListener(t, func(n int, server mockConn) {
+			if !server.acceptAuth(pw) {
+				return
+			}
+
+			for {
+				request, err := server.readPacket()
+				if nil != err || 0 == n {
+					return
+				}
+
+				server.respond(request.Header.challenge, responseValue, request.Body)
+			}
+		})
+
+		addr := listener.Addr().(*net.TCPAddr)
+
+		c := NewClient(addr.IP.String(), addr.Port, pw)
+		c.ReplayQueuedOnReconnect = replay
+
+		if err := c.Connect(); nil != err {
+			t.Fatal("Expected no error during connect", err)
+		} else if _, err = c.Authorize(); nil != err {
+			t.Fatal("Expected no error during authorize", err)
+		}
+
+		dropped, queued := c.ExecuteAsync("dropped"), c.ExecuteAsync("queued")
+
+		if result := <-dropped; nil == result.Err {
+			t.Log("Expected the command in flight to fail as the connection drops", result)
+			t.Fail()
+		}
+
+		if result := <-queued; replay && (nil != result.Err || "queued" != result.Packet.Body) {
+			t.Log("Expected the queued command to run after reconnecting", result)
+			t.Fail()
+		} else if !replay && nil == result.Err {
+			t.Log("Expected the queued command to fail without reconnecting", result)
+			t.Fail()
+		}
+
+		c.Disconnect()
+		listener.Close()
+	}
+}
+
+func TestMockCorrelateByOrder(t *testing.T) {
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			// Never mirror the challenge; reject authorization with -1.
+			if request.Header.headerType == auth {
+				conn.respond(request.Header.challenge, responseValue, "")
+				conn.respond(-1, authResponse, "")
+			} else {
+				conn.respond(0, responseValue, request.Body)
+			}
+		}
+	})
+	defer server.close()
+
+	c := server.client(t, pw)
+	defer c.Disconnect()
+
+	c.authorized = true
+
+	if _, err := c.Execute("first"); !errors.Is(err, ErrInvalidChallenge) {
+		t.Log("Expected ErrInvalidChallenge from an unmirrored challenge, got", err)
+		t.Fail()
+	}
+
+	c.CorrelateByOrder = true
+
+	if response, err := c.Execute("second"); nil != err || "second" != response.Body {
+		t.Log("Expected the response following the command", response, err)
+		t.Fail()
+	}
+
+	if _, err := c.Authorize(); nil == err {
+		t.Log("Expected authorization to still check the challenge")
+		t.Fail()
+	}
+}
+
+func TestMockStableChallengeOnRetry(t *testing.T) {
+	challenges := make(map[string][]int32)
+
+	server := newMockServer(t, func(conn mockConn) {
+		for {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			// Mismatch every command's first response, and each of the
+			// "always" command's.
+			challenge := request.Header.challenge
+			if 0 == len(challenges[request.Body]) || "always" == request.Body {
+				challenge++
+			}
+
+			challenges[request.Body] = append(challenges[request.Body], request.Header.challenge)
+			conn.respond(challenge, responseValue, request.Body)
+		}
+	})
+
+	c := server.client(t, pw)
+	c.authorized = true
+	c.RetryOnChallengeMismatch = true
+	c.StableChallengeOnRetry = true
+
+	if response, err := c.Execute("once"); nil != err || "once" != response.Body {
+		t.Log("Expected the retried command's own response", response, err)
+		t.Fail()
+	}
+
+	if _, err := c.Execute("always"); !errors.Is(err, ErrInvalidChallenge) {
+		t.Log("Expected ErrInvalidChallenge once the retry mismatches too, got", err)
+		t.Fail()
+	}
+
+	c.StableChallengeOnRetry = false
+
+	if _, err := c.Execute("fresh"); nil != err {
+		t.Log("Expected no error retrying with a fresh challenge", err)
+		t.Fail()
+	}
+
+	c.Disconnect()
+	server.close()
+
+	if sent := challenges["once"]; 2 != len(sent) || sent[0] != sent[1] {
+		t.Log("Expected the retry sent with the same challenge", sent)
+		t.Fail()
+	}
+
+	if sent := challenges["always"]; 2 != len(sent) {
+		t.Log("Expected a single retry", sent)
+		t.Fail()
+	}
+
+	if sent := challenges["fresh"]; 2 != len(sent) || sent[0] == sent[1] {
+		t.Log("Expected the retry sent with a fresh challenge", sent)
+		t.Fail()
+	}
+}
+
+func TestMockShutdown(t *testing.T) {
+	for _, expired := range []bool{false, true} {
+		received := make(chan struct{})
+
+		server := newMockServer(t, func(conn mockConn) {
+			request, err := conn.readPacket()
+			if nil != err {
+				return
+			}
+
+			close(received)
+
+			// Answer late, or not at all once the shutdown's expired.
+			if !expired {
+				time.Sleep(100 * time.Millisecond)
+				conn.respond(request.Header.challenge, responseValue, "done")
+			}
+
+			conn.readPacket()
+		})
+
+		c := server.client(t, pw)
+		c.authorized = true
+
+		results := make(chan Result, 1)
+		go func() {
+			response, err := c.Execute("slow")
+			results <- Result{response, err}
+		}()
+
+		<-received
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		if !expired {
+			ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+		}
+
+		err := c.Shutdown(ctx)
+		cancel()
+
+		if result := <-results; expired && (!errors.Is(err, context.DeadlineExceeded) || nil == result.Err) {
+			t.Log("Expected the expired shutdown to abort the command", err, result)
+			t.Fail()
+		} else if !expired && (nil != err || nil != result.Err || "done" != result.Packet.Body) {
+			t.Log("Expected the command in flight to complete before shutting down", err, result)
+			t.Fail()
+		}
+
+		if _, err := c.Execute("after"); !errors.Is(err, ErrClientShutdown) {
+			t.Log("Expected ErrClientShutdown after shutting down, got", err)
+			t.Fail()
+		}
+
+		server.close()
+	}
+}
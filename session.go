@@ -0,0 +1,157 @@
+package rcon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrReconnectBudgetExhausted is returned in place of reconnecting once a
+// client's attempted MaxReconnects reconnections.
+var ErrReconnectBudgetExhausted = errors.New("Client exhausted its reconnection attempts.")
+
+// PersistentSession keeps the client connected and authorized until it
+// disconnects. Every keepAlive interval an empty command is sent; should
+// it fail, because the server expired the session or closed the
+// connection, the client transparently reconnects and authorizes again
+// with the password. Failed reconnects are retried on the next interval.
+//
+// The returned channel receives the error if authorization itself fails,
+// e.g. because the password changed, or the client exhausts MaxReconnects,
+// after which the session stops. The channel is closed when the session
+// stops.
+func (this *Client) PersistentSession(password string, keepAlive time.Duration) <-chan error {
+	this.stopSession()
+
+	this.sessionMutex.Lock()
+	defer this.sessionMutex.Unlock()
+
+	// The password's read by reconnections, under the command lock.
+	this.mutex.Lock()
+	this.password = password
+	this.secretAuth = false
+	this.mutex.Unlock()
+
+	this.session = make(chan struct{})
+
+	errs := make(chan error, 1)
+
+	go this.keepSession(this.session, keepAlive, errs)
+
+	return errs
+}
+
+// keepSession runs the persistent session until it's stopped or
+// authorization fails.
+func (this *Client) keepSession(stop chan struct{}, keepAlive time.Duration, errs chan error) {
+	defer close(errs)
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := this.Execute(""); nil == err {
+			continue
+		}
+
+		if err := this.reconnect(); errors.Is(err, ErrFailedAuthorization) || errors.Is(err, ErrInvalidChallenge) || errors.Is(err, ErrReconnectBudgetExhausted) {
+			errs <- err
+			return
+		}
+	}
+}
+
+// stopSession stops the client's persistent session, if running.
+func (this *Client) stopSession() {
+	this.sessionMutex.Lock()
+	defer this.sessionMutex.Unlock()
+
+	if nil != this.session {
+		close(this.session)
+		this.session = nil
+	}
+}
+
+// reconnect closes the client's connection, if open, then connects and
+// authorizes it again with the stored password. A server rejecting the
+// password responds with a challenge of -1, failing the authorization
+// with ErrInvalidChallenge.
+func (this *Client) reconnect() (err error) {
+	defer this.wrapError(&err)
+
+	if err = this.lockConnection(); nil != err {
+		return
+	}
+	defer this.mutex.Unlock()
+
+	this.idleClosed.Store(false)
+	this.armIdle()
+
+	return this.redial()
+}
+
+// redial replaces the client's connection with a new one, checking it as
+// Connect does, and authorizes it again with the password. The client's
+// command lock must be held.
+func (this *Client) redial() (err error) {
+	if 0 < this.MaxReconnects && int64(this.MaxReconnects) <= this.reconnectAttempts.Load() {
+		return fmt.Errorf("%w Limit is %d.", ErrReconnectBudgetExhausted, this.MaxReconnects)
+	}
+
+	this.reconnectAttempts.Add(1)
+	this.metrics.reconnects.Add(1)
+
+	if nil != this.connection {
+		this.connection.Close()
+	}
+
+	this.authorized = false
+
+	if this.connection, err = this.dial(); nil != err {
+		return
+	}
+
+	this.setDeadline()
+
+	if err = this.opened(); nil != err {
+		this.connection.Close()
+		return
+	}
+
+	// Authorized with a password that wasn't kept, the client's left to
+	// AuthorizeReader to authorize again.
+	if this.secretAuth {
+		return
+	}
+
+	var response *Packet
+
+	if response, err = this.exchange(auth, this.password); nil != err {
+		return
+	} else if response.Header.headerType != this.AuthResponseType {
+		return ErrFailedAuthorization
+	}
+
+	this.authorized = true
+
+	return
+}
+
+// ReconnectAttempts returns the number of reconnections attempted since the
+// client was created or ResetReconnects was last called, successful or not,
+// for monitoring how close the client is to exhausting MaxReconnects.
+func (this *Client) ReconnectAttempts() int {
+	return int(this.reconnectAttempts.Load())
+}
+
+// ResetReconnects resets the count of reconnections attempted, renewing the
+// client's MaxReconnects budget. Metrics keeps counting every reconnection.
+func (this *Client) ResetReconnects() {
+	this.reconnectAttempts.Store(0)
+}
@@ -0,0 +1,66 @@
+package rcon
+
+import (
+	"errors"
+	"sync"
+)
+
+// Shared client errors.
+var (
+	ErrNotAcquired = errors.New("Shared client released more often than acquired.")
+)
+
+// SharedClient shares one connected and authorized client between several
+// users, such as the components of a plugin system, counting references to
+// it: the client connects on the first Acquire and disconnects once the last
+// reference is released. It's safe for concurrent use.
+type SharedClient struct {
+	client     *Client
+	references int
+	mutex      sync.Mutex
+}
+
+// NewSharedClient shares the client, which mustn't be connected.
+func NewSharedClient(client *Client) *SharedClient {
+	return &SharedClient{client: client}
+}
+
+// Acquire returns the client, connecting and authorizing it if it's the
+// first reference. Each successful Acquire must be paired with a Release.
+func (this *SharedClient) Acquire() (client *Client, err error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if 0 == this.references {
+		if err = this.client.Connect(); nil != err {
+			return
+		} else if _, err = this.client.Authorize(); nil != err {
+			this.client.Disconnect()
+			return
+		}
+	}
+
+	this.references++
+
+	return this.client, nil
+}
+
+// Release releases a reference to the client, disconnecting it if it was
+// the last. ErrNotAcquired is returned, and nothing released, should there
+// be no reference left.
+func (this *SharedClient) Release() error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if 0 == this.references {
+		return ErrNotAcquired
+	}
+
+	this.references--
+
+	if 0 == this.references {
+		return this.client.Disconnect()
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package rcon
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ServerStats holds the server's performance figures as reported by the
+// stats command.
+type ServerStats struct {
+	CPU        float64 // CPU usage, in percent.
+	NetIn      float64 // Incoming traffic, in KB/s.
+	NetOut     float64 // Outgoing traffic, in KB/s.
+	Uptime     int     // Uptime, in minutes.
+	MapChanges int     // The number of map changes.
+	FPS        float64 // The server's frame rate.
+	Players    int     // The number of players connected.
+	Connects   int     // The number of connections made, if reported.
+	Svms       float64 // Average server frame time in ms, if reported.
+	SvmsJitter float64 // Variance of the server frame time in ms, if reported.
+	TickTime   float64 // Average tick time in ms, if reported.
+}
+
+// Stats runs the stats command and parses the server's performance figures.
+func (this *Client) Stats() (stats *ServerStats, err error) {
+	var response *Packet
+
+	if response, err = this.Execute("stats"); nil != err {
+		return
+	}
+
+	return ParseStats(response.Body)
+}
+
+// ParseStats parses the body of a stats response. The figures are laid out
+// in whitespace aligned columns beneath a header line, which differ between
+// games, e.g.
+//
+//	CPU    In (KB/s)  Out (KB/s)  Uptime  Map changes  FPS      Players  Connects
+//	0.00   0.00       0.00        27      0            66.67    0        0
+//
+// or
+//
+//	CPU   NetIn   NetOut    Uptime  Maps   FPS   Players  Svms    +-ms   ~tick
+//	10.0      0.0      0.0       0     0  128.00       0    0.33    0.05    0.03
+//
+// The seven leading columns are common to both, the trailing ones are
+// identified by the header line.
+func ParseStats(body string) (stats *ServerStats, err error) {
+	var header, values []string
+
+	for _, line := range strings.Split(body, "\n") {
+		if fields := strings.Fields(line); 0 == len(fields) {
+			continue
+		} else if "CPU" == fields[0] {
+			header = fields
+		} else if nil != header {
+			values = fields
+			break
+		}
+	}
+
+	if len(values) < 7 {
+		return nil, ErrUnexpectedResponse
+	}
+
+	numbers := make([]float64, len(values))
+
+	for i, value := range values {
+		if numbers[i], err = strconv.ParseFloat(value, 64); nil != err {
+			return nil, ErrUnexpectedResponse
+		}
+	}
+
+	stats = &ServerStats{
+		CPU:        numbers[0],
+		NetIn:      numbers[1],
+		NetOut:     numbers[2],
+		Uptime:     int(numbers[3]),
+		MapChanges: int(numbers[4]),
+		FPS:        numbers[5],
+		Players:    int(numbers[6]),
+	}
+
+	extra := numbers[7:]
+
+	switch last := header[len(header)-1]; {
+	case "Connects" == last && len(extra) >= 1:
+		stats.Connects = int(extra[0])
+	case "~tick" == last && len(extra) >= 3:
+		stats.Svms, stats.SvmsJitter, stats.TickTime = extra[0], extra[1], extra[2]
+	}
+
+	return
+}
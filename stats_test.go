@@ -0,0 +1,34 @@
+package rcon
+
+import "testing"
+
+func TestParseStats(t *testing.T) {
+	stats, err := ParseStats("CPU    In (KB/s)  Out (KB/s)  Uptime  Map changes  FPS      Players  Connects\n" +
+		"0.00   1.50       2.25        27      3            66.67    4        5\n")
+	if nil != err {
+		t.Log("Expected no error during parse", err)
+		t.FailNow()
+	}
+
+	if *stats != (ServerStats{CPU: 0, NetIn: 1.5, NetOut: 2.25, Uptime: 27, MapChanges: 3, FPS: 66.67, Players: 4, Connects: 5}) {
+		t.Log("Unexpected stats", *stats)
+		t.Fail()
+	}
+
+	stats, err = ParseStats("  CPU   NetIn   NetOut    Uptime  Maps   FPS   Players  Svms    +-ms   ~tick\n" +
+		"  10.0      0.0      0.0       0     0  128.00       0    0.33    0.05    0.03\n")
+	if nil != err {
+		t.Log("Expected no error during parse", err)
+		t.FailNow()
+	}
+
+	if stats.CPU != 10 || stats.FPS != 128 || stats.Svms != 0.33 || stats.SvmsJitter != 0.05 || stats.TickTime != 0.03 {
+		t.Log("Unexpected stats", *stats)
+		t.Fail()
+	}
+
+	if _, err = ParseStats("Unknown command \"stats\"\n"); ErrUnexpectedResponse != err {
+		t.Log("Expected ErrUnexpectedResponse, got", err)
+		t.Fail()
+	}
+}
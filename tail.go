@@ -0,0 +1,93 @@
+package rcon
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// TailConsole streams the server's console output, for servers pushing it
+// over the RCON connection. Stock Valve servers don't: they forward logs
+// over UDP to addresses added with logaddress_add instead. Some mods and
+// server wrappers do push console output as unsolicited packets, often
+// once enabled by a command, which is set as TailConsoleCommand and run
+// before tailing starts.
+//
+// Each line pushed is sent on the returned channel, which is closed once
+// ctx is cancelled or the connection fails for good. Should the connection
+// break, the client reconnects, authorizes and enables tailing again.
+// Cancelling ctx stops waiting for output immediately; a reconnection in
+// progress completes first, within the client's DialTimeout and Timeout,
+// as does running TailConsoleCommand, within its Timeout. The
+// client's command lock is held while waiting for output, so other commands
+// block until the next line arrives; use a separate client for them.
+func (this *Client) TailConsole(ctx context.Context) (lines <-chan string, err error) {
+	if "" != this.TailConsoleCommand {
+		if _, err = this.Execute(this.TailConsoleCommand); nil != err {
+			return
+		}
+	}
+
+	output := make(chan string)
+
+	go this.tail(ctx, output)
+
+	return output, nil
+}
+
+// tail reads the packets pushed by the server, sending each line of their
+// bodies as output, until ctx is cancelled.
+func (this *Client) tail(ctx context.Context, output chan<- string) {
+	defer close(output)
+
+	for {
+		packet, err := this.readPushed(ctx)
+
+		if nil != ctx.Err() {
+			return
+		} else if nil != err {
+			if !isConnectionError(err) || nil != this.reconnect() {
+				return
+			} else if nil != ctx.Err() {
+				return
+			} else if "" != this.TailConsoleCommand {
+				if _, err = this.Execute(this.TailConsoleCommand); nil != err {
+					return
+				}
+			}
+
+			continue
+		}
+
+		for _, line := range strings.Split(packet.Body, "\n") {
+			if line = strings.TrimRight(line, "\r"); "" == line {
+				continue
+			}
+
+			select {
+			case output <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// readPushed reads a packet pushed by the server, waiting without a
+// deadline until one arrives or ctx is cancelled.
+func (this *Client) readPushed(ctx context.Context) (packet *Packet, err error) {
+	if err = this.lock(); nil != err {
+		return
+	}
+	defer this.mutex.Unlock()
+
+	this.connection.SetDeadline(time.Time{})
+
+	connection := this.connection
+	stop := context.AfterFunc(ctx, func() {
+		connection.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	return this.readPacket()
+}
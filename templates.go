@@ -0,0 +1,159 @@
+package rcon
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Template errors.
+var (
+	ErrUnknownTemplate = errors.New("No command template is registered under the name.")
+	ErrInvalidArgument = errors.New("Command argument doesn't satisfy its template.")
+)
+
+// Arg describes an argument of a command template.
+type Arg struct {
+	Name     string         // The argument's name, its key in ExecuteTemplate's args.
+	Required bool           // Must the argument be given?
+	Pattern  *regexp.Regexp // An optional pattern the argument must match.
+	Help     string         // A description of the argument, e.g. for forms.
+}
+
+// ArgSpec describes a command and its arguments, for validating them before
+// the command's sent, and for generating forms for it.
+type ArgSpec struct {
+	Command string // The console command, the name registered under if empty.
+	Args    []Arg  // The arguments, in the order they're passed.
+	Help    string // A description of the command.
+}
+
+// Patterns of common arguments.
+var (
+	userIDPattern  = regexp.MustCompile(`^\d+$`)
+	steamIDPattern = regexp.MustCompile(`^(STEAM_[0-5]:[01]:\d+|\[U:1:\d+\])$`)
+	minutesPattern = regexp.MustCompile(`^\d+$`)
+	mapPattern     = regexp.MustCompile(`^[\w\-/.]+$`)
+)
+
+var (
+	templates = map[string]ArgSpec{
+		"kick": {
+			Command: "kickid",
+			Help:    "Kicks a player, showing them the reason.",
+			Args: []Arg{
+				{Name: "userid", Required: true, Pattern: userIDPattern, Help: "The player's user ID, as listed by status."},
+				{Name: "reason", Help: "The reason shown to the player."},
+			},
+		},
+		"ban": {
+			Command: "banid",
+			Help:    "Bans a player for a number of minutes, permanently if zero.",
+			Args: []Arg{
+				{Name: "minutes", Required: true, Pattern: minutesPattern, Help: "The ban's duration in minutes."},
+				{Name: "steamid", Required: true, Pattern: steamIDPattern, Help: "The player's Steam ID."},
+			},
+		},
+		"changelevel": {
+			Help: "Changes the map.",
+			Args: []Arg{
+				{Name: "map", Required: true, Pattern: mapPattern, Help: "The name of the map."},
+			},
+		},
+	}
+	templatesMutex sync.RWMutex
+)
+
+// RegisterCommand registers the command template under the name, replacing
+// any registered before, including the built-in kick, ban and changelevel.
+func RegisterCommand(name string, spec ArgSpec) {
+	templatesMutex.Lock()
+	defer templatesMutex.Unlock()
+
+	templates[name] = spec
+}
+
+// Templates returns the names of the registered command templates, sorted.
+func Templates() (names []string) {
+	templatesMutex.RLock()
+	defer templatesMutex.RUnlock()
+
+	for name := range templates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return
+}
+
+// Template returns the command template registered under the name.
+func Template(name string) (spec ArgSpec, ok bool) {
+	templatesMutex.RLock()
+	defer templatesMutex.RUnlock()
+
+	spec, ok = templates[name]
+
+	return
+}
+
+// ExecuteTemplate executes the command registered under the name with the
+// arguments, keyed by their names. The arguments are validated against the
+// template before anything is sent, failing with ErrInvalidArgument should
+// one be missing, unknown or not match its pattern, and quoted as with
+// ExecuteArgs. Optional arguments left out are passed empty, unless no
+// argument after them is given, in which case they're omitted.
+func (this *Client) ExecuteTemplate(name string, args map[string]string) (response *Packet, err error) {
+	spec, ok := Template(name)
+	if !ok {
+		return nil, fmt.Errorf("%w Name is %q.", ErrUnknownTemplate, name)
+	}
+
+	var values []string
+
+	if values, err = spec.validate(args); nil != err {
+		return
+	}
+
+	command := spec.Command
+	if "" == command {
+		command = name
+	}
+
+	return this.ExecuteArgs(command, values...)
+}
+
+// validate checks the arguments against the spec, returning their values
+// in order, up to the last given.
+func (this ArgSpec) validate(args map[string]string) (values []string, err error) {
+	known := make(map[string]bool, len(this.Args))
+	given := 0
+
+	for i, arg := range this.Args {
+		known[arg.Name] = true
+
+		value, ok := args[arg.Name]
+
+		if !ok {
+			if arg.Required {
+				return nil, fmt.Errorf("%w Argument %q is required.", ErrInvalidArgument, arg.Name)
+			}
+		} else if nil != arg.Pattern && !arg.Pattern.MatchString(value) {
+			return nil, fmt.Errorf("%w Argument %q doesn't match %s.", ErrInvalidArgument, arg.Name, arg.Pattern)
+		} else {
+			given = i + 1
+		}
+
+		values = append(values, value)
+	}
+
+	for name := range args {
+		if !known[name] {
+			return nil, fmt.Errorf("%w Argument %q is unknown.", ErrInvalidArgument, name)
+		}
+	}
+
+	return values[:given], nil
+}
@@ -0,0 +1,40 @@
+package rcon
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExecuteTemplate(t *testing.T) {
+	var entries logEntries
+
+	c := NewClient(hostname, port, pw)
+	c.DryRun = true
+	c.Logger = &entries
+
+	if _, err := c.ExecuteTemplate("kick", map[string]string{"userid": "12"}); nil != err {
+		t.Log("Expected no error executing the kick template", err)
+		t.FailNow()
+	} else if `kickid "12"` != entries[0].Command {
+		t.Log("Unexpected command", entries[0].Command)
+		t.Fail()
+	}
+
+	invalid := []map[string]string{
+		{"reason": "cheating"},
+		{"userid": "12; quit"},
+		{"userid": "12", "unknown": ""},
+	}
+
+	for _, args := range invalid {
+		if _, err := c.ExecuteTemplate("kick", args); !errors.Is(err, ErrInvalidArgument) {
+			t.Log("Expected ErrInvalidArgument for", args, "got", err)
+			t.Fail()
+		}
+	}
+
+	if _, err := c.ExecuteTemplate("unregistered", nil); !errors.Is(err, ErrUnknownTemplate) {
+		t.Log("Expected ErrUnknownTemplate, got", err)
+		t.Fail()
+	}
+}
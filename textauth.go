@@ -0,0 +1,126 @@
+package rcon
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// Time allowed for a server to prompt for the password before it's
+// assumed to speak the binary protocol.
+const textPromptTimeout = 250 * time.Millisecond
+
+// Responses, matched case insensitively, with which text prompting servers
+// accept or reject the password. Failures are matched first, so negated
+// forms of the successes, such as "unsuccessful", must be among them.
+var (
+	textAuthSuccess = []string{"authenticated", "logged in", "success", "welcome"}
+	textAuthFailure = []string{
+		"denied", "incorrect", "wrong", "invalid", "failed", "failure",
+		"unsuccessful", "unauthorized", "not authenticated", "not authorized", "not logged in",
+	}
+)
+
+// authorizeText authorizes to servers prompting for the password as text,
+// such as some RCON proxies, rather than speaking the binary protocol.
+// The server is given a moment to send a prompt; if it sends text, the
+// password is written followed by a newline, and its responses read until
+// one accepts or rejects it. prompted is false, and nothing written, if
+// the server sends no prompt.
+func (this *Client) authorizeText() (response *Packet, prompted bool, err error) {
+	defer this.wrapError(&err)
+
+	if err = this.lock(); nil != err {
+		return
+	}
+	defer this.mutex.Unlock()
+
+	var text bytes.Buffer
+
+	if prompted, err = this.readText(&text, textPromptTimeout); !prompted {
+		return
+	}
+
+	if _, err = this.writeSecret([]byte(this.password + "\n")); nil != err {
+		return
+	}
+
+	text.Reset()
+
+	for {
+		reply := strings.ToLower(text.String())
+
+		for _, token := range textAuthFailure {
+			if strings.Contains(reply, token) {
+				err = ErrFailedAuthorization
+				return
+			}
+		}
+
+		for _, token := range textAuthSuccess {
+			if strings.Contains(reply, token) {
+				this.authorized = true
+				this.secretAuth = false
+				response = newPacket(0, authResponse, strings.TrimSpace(text.String()), packetPaddingSize)
+				return
+			}
+		}
+
+		var ok bool
+
+		if ok, err = this.readText(&text, this.Timeout); nil != err {
+			return
+		} else if !ok {
+			// The server fell silent or stopped speaking text.
+			err = ErrFailedAuthorization
+			return
+		}
+	}
+}
+
+// readText reads whatever the server sends within the timeout, without
+// limit if zero, into text, reporting whether it's text rather than binary.
+// Binary is only peeked at, not consumed, so it's still read as packets. A
+// timeout with nothing read isn't an error.
+func (this *Client) readText(text *bytes.Buffer, timeout time.Duration) (ok bool, err error) {
+	reader := this.peeker()
+
+	if timeout > 0 {
+		this.connection.SetReadDeadline(time.Now().Add(timeout))
+		defer this.setDeadline()
+	}
+
+	if _, err = reader.Peek(1); nil != err {
+		var ne net.Error
+
+		if errors.As(err, &ne) && ne.Timeout() {
+			err = nil
+		}
+
+		return
+	}
+
+	peeked, _ := reader.Peek(reader.Buffered())
+
+	if !isText(peeked) {
+		return
+	}
+
+	text.Write(peeked)
+	reader.Discard(len(peeked))
+
+	return true, nil
+}
+
+// isText reports whether b is printable ASCII text and line breaks.
+func isText(b []byte) bool {
+	for _, c := range b {
+		if (c < ' ' || c > '~') && '\n' != c && '\r' != c && '\t' != c {
+			return false
+		}
+	}
+
+	return true
+}
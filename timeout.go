@@ -0,0 +1,27 @@
+package rcon
+
+import (
+	"net"
+)
+
+// ErrTimeout is returned when dialing or a command exceeds the client's
+// timeouts. It implements net.Error, so callers may check for timeouts
+// either with errors.Is(err, ErrTimeout) or, as for any network error,
+//
+//	var ne net.Error
+//	if errors.As(err, &ne) && ne.Timeout() {
+var ErrTimeout net.Error = timeoutError{}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "Timed out communicating with the remote server." }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// translateTimeout replaces the error pointed to with ErrTimeout if it's
+// a network timeout.
+func translateTimeout(err *error) {
+	if ne, ok := (*err).(net.Error); ok && ne.Timeout() {
+		*err = ErrTimeout
+	}
+}